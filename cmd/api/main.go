@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -15,25 +16,36 @@ import (
 	"github.com/iammm0/physics-llm/internal/config"
 	"github.com/iammm0/physics-llm/internal/handler"
 	"github.com/iammm0/physics-llm/internal/ingest"
-	"github.com/iammm0/physics-llm/internal/store"
 )
 
 func main() {
 	// 1. 加载配置
 	cfg := config.LoadConfig()
 
-	// 2. 初始化 Qdrant 客户端并确保 collection 存在
-	db := store.NewClient(cfg)
-	if err := db.EnsureCollection(cfg.EmbedDim); err != nil {
-		log.Fatalf("qdrant 初始化失败: %v", err)
+	skipIngest := flag.Bool("skip-ingest", cfg.SkipBootIngest, "启动时跳过知识库导入（可用 cmd/ingest 单独导入）")
+	flag.Parse()
+
+	// 2. 用探测文本核对 Embedding 模型的维度与已有集合（Qdrant 或内嵌实现，见 VECTOR_STORE），并确保 collection 存在；
+	//    EMBED_MISMATCH=migrate 时可能改用新集合，之后的导入与检索都用它
+	if err := ingest.CheckEmbedding(context.Background(), cfg); err != nil {
+		log.Fatalf("向量库初始化失败: %v", err)
 	}
 
 	// 3. 批量导入知识库文件到 Qdrant
-	//    10 分钟超时，导入过程中会自动分片、生成 embedding 并 upsert
-	ingestCtx, ingestCancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer ingestCancel()
-	if err := ingest.Run(ingestCtx, cfg); err != nil {
-		log.Fatalf("文档导入失败: %v", err)
+	//    默认 10 分钟超时（INGEST_TIMEOUT），导入过程中会自动分片、生成 embedding 并 upsert；
+	//    大型知识库建议 -skip-ingest 后用 cmd/ingest 单独导入
+	if *skipIngest {
+		log.Println("已跳过启动时的知识库导入")
+	} else {
+		ingestCtx, ingestCancel := context.Background(), context.CancelFunc(func() {})
+		if cfg.IngestTimeout > 0 {
+			ingestCtx, ingestCancel = context.WithTimeout(ingestCtx, cfg.IngestTimeout)
+		}
+		err := ingest.Run(ingestCtx, cfg)
+		ingestCancel()
+		if err != nil {
+			log.Fatalf("文档导入失败: %v", err)
+		}
 	}
 
 	// 4. 设置 Gin 路由
@@ -41,16 +53,18 @@ func main() {
 
 	// **注册 CORS 中间件**
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173"}, // 前端地址
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
+		AllowOrigins:     cfg.AllowOrigins, // 前端地址，默认 http://localhost:5173
+		AllowMethods:     []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// 注册路由
-	handler.RegisterRoutes(router, cfg)
+	if err := handler.RegisterRoutes(router, cfg); err != nil {
+		log.Fatalf("路由初始化失败: %v", err)
+	}
 
 	// 启动 HTTP 服务
 	srv := &http.Server{
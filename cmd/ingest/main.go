@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/ingest"
+	"github.com/iammm0/physics-llm/internal/llm/provider"
+)
+
+const usage = `用法: ingest <命令> [参数]
+
+命令:
+  sync               增量同步整个知识库目录（与 API 启动时的导入相同）
+  add [-force] <路径> 导入知识库目录内的指定文件或子目录
+  reindex            忽略清单，强制重导全部文件
+  remove <source>    删除某个 source 的全部向量并移出清单（不删除磁盘文件）
+  list               列出清单中已导入的文件
+  stats              汇总文件数、切片数与向量库中的点数
+  dry-run            只显示 sync 将要执行的操作，不做任何写入
+
+版本管理（仅 Qdrant，QDRANT_COLLECTION 作为别名指向当前版本）:
+  rebuild [-drop-legacy]
+                     蓝绿重建：全部导入新版本集合 {QDRANT_COLLECTION}_vN，成功后切换别名；
+                     QDRANT_COLLECTION 还是普通集合时默认拒绝，-drop-legacy 删除它后再切换（之后无法回滚到它）
+  versions           列出全部版本
+  rollback [版本]     把别名切回上一个版本，或指定版本（如 v2）
+  gc [-keep N]       删除旧版本，保留当前版本与最新的 N 个其它版本（默认 1）
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Ctrl-C 时取消进行中的 Embedding / Upsert，已完成的文件已写入清单
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg := config.LoadConfig()
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	switch cmd {
+	case "sync", "add", "reindex", "remove", "stats":
+		// 核对 Embedding 模型与集合；EMBED_MISMATCH=migrate 时会改用新集合，须在创建 Ingester 之前
+		if err := ingest.CheckEmbedding(ctx, cfg); err != nil {
+			log.Fatalf("向量库初始化失败: %v", err)
+		}
+	}
+
+	in, err := ingest.New(cfg)
+	if err != nil {
+		log.Fatalf("初始化失败: %v", err)
+	}
+
+	switch cmd {
+	case "sync":
+		report(in.Sync(ctx))
+
+	case "add":
+		fs := flag.NewFlagSet("add", flag.ExitOnError)
+		force := fs.Bool("force", false, "已导入且未变化的文件也重新导入")
+		_ = fs.Parse(args)
+		if fs.NArg() != 1 {
+			log.Fatal("用法: ingest add [-force] <路径>")
+		}
+		report(in.AddPath(ctx, fs.Arg(0), *force))
+
+	case "reindex":
+		report(in.Reindex(ctx))
+
+	case "remove":
+		if len(args) != 1 {
+			log.Fatal("用法: ingest remove <source>")
+		}
+		if _, ok := in.Manifest().Get(args[0]); !ok {
+			log.Printf("清单中没有 %s，仍尝试删除其向量", args[0])
+		}
+		if err := in.Remove(ctx, args[0]); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("已删除 %s（若文件仍在知识库目录中，下次 sync 会重新导入）", args[0])
+
+	case "list":
+		list(in.Manifest())
+
+	case "stats":
+		stats(ctx, cfg, in)
+
+	case "dry-run":
+		plan, err := in.Plan()
+		if err != nil {
+			log.Fatal(err)
+		}
+		dryRun(plan)
+
+	case "rebuild":
+		fs := flag.NewFlagSet("rebuild", flag.ExitOnError)
+		dropLegacy := fs.Bool("drop-legacy", false, "删除与别名同名的普通集合后再切换")
+		_ = fs.Parse(args)
+		name, rep, err := ingest.Rebuild(ctx, cfg, nil, *dropLegacy)
+		report(rep, err)
+		log.Printf("已切换到新版本 %s", name)
+
+	case "versions":
+		versions(ctx, cfg)
+
+	case "rollback":
+		if len(args) > 1 {
+			log.Fatal("用法: ingest rollback [版本]")
+		}
+		var target string
+		if len(args) == 1 {
+			target = args[0]
+		}
+		if _, err := ingest.Rollback(ctx, cfg, target); err != nil {
+			log.Fatal(err)
+		}
+
+	case "gc":
+		fs := flag.NewFlagSet("gc", flag.ExitOnError)
+		keep := fs.Int("keep", 1, "除当前版本外保留的最新版本数")
+		_ = fs.Parse(args)
+		dropped, err := ingest.GC(ctx, cfg, *keep)
+		for _, name := range dropped {
+			log.Printf("已删除版本 %s", name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(dropped) == 0 {
+			log.Println("没有需要删除的版本")
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func report(rep ingest.Report, err error) {
+	log.Printf("导入 %d，未变化 %d，清理 %d，失败 %d", rep.Ingested, rep.Skipped, rep.Removed, rep.Failed)
+	if err != nil {
+		log.Fatalf("导入中断: %v", err)
+	}
+}
+
+func list(m *ingest.Manifest) {
+	files := m.Snapshot()
+	sources := make([]string, 0, len(files))
+	for s := range files {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tCHUNKS\tSIZE\tINGESTED")
+	for _, s := range sources {
+		st := files[s]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", s, st.Chunks, st.Size, st.IngestedAt.Format(time.DateTime))
+	}
+	_ = tw.Flush()
+}
+
+func stats(ctx context.Context, cfg *config.Config, in *ingest.Ingester) {
+	files := in.Manifest().Snapshot()
+	var chunks int
+	var size int64
+	for _, st := range files {
+		chunks += st.Chunks
+		size += st.Size
+	}
+	fmt.Printf("文件数:      %d\n", len(files))
+	fmt.Printf("切片数:      %d\n", chunks)
+	fmt.Printf("原始大小:    %.1f MiB\n", float64(size)/(1<<20))
+	fmt.Printf("关键词索引:  %d 个片段 (%s)\n", in.Keywords().Len(), cfg.KeywordIndex)
+	fmt.Printf("Embedding:   %s (%d 维)\n", provider.EmbedModel(cfg), cfg.EmbedDim)
+
+	points, err := in.Store().Count(ctx)
+	if err != nil {
+		fmt.Printf("向量库点数:  获取失败 (%v)\n", err)
+		return
+	}
+	fmt.Printf("向量库点数:  %d (%s, collection %s)\n", points, cfg.VectorStore, cfg.QdrantCol)
+	if points != chunks {
+		fmt.Println("注意: 点数与清单切片数不一致，可执行 reindex 修复")
+	}
+}
+
+func versions(ctx context.Context, cfg *config.Config) {
+	vs, err := ingest.ListVersions(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(vs) == 0 {
+		fmt.Printf("还没有版本，执行 rebuild 构建第一个版本（别名 %s）\n", cfg.QdrantCol)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tVERSION\tPOINTS\tFILES\tEMBED MODEL\tCHUNKER\tBUILT")
+	for _, v := range vs {
+		mark, built := "", "-"
+		if v.Active {
+			mark = "*"
+		}
+		if !v.BuiltAt.IsZero() {
+			built = v.BuiltAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", mark, v.Name, v.Points, v.Files, v.EmbedModel, v.Chunker, built)
+	}
+	_ = tw.Flush()
+}
+
+func dryRun(plan []ingest.PlanItem) {
+	counts := map[ingest.Action]int{}
+	for _, item := range plan {
+		counts[item.Action]++
+		if item.Action != ingest.ActionSkip {
+			fmt.Printf("%-7s %s\n", item.Action, item.Source)
+		}
+	}
+	fmt.Printf("新增 %d，更新 %d，未变化 %d，清理 %d\n",
+		counts[ingest.ActionAdd], counts[ingest.ActionUpdate], counts[ingest.ActionSkip], counts[ingest.ActionRemove])
+}
@@ -18,9 +18,11 @@ require (
 	github.com/PuerkitoBio/goquery v1.10.3
 	github.com/gin-contrib/cors v1.7.6
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/ledongthuc/pdf v0.0.0-20250511090121-5959a4027728
 	github.com/otiai10/gosseract/v2 v2.4.1
 	github.com/unidoc/unioffice v1.39.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -61,5 +63,4 @@ require (
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
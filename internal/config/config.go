@@ -4,20 +4,64 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
 	"log"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	APIAddr          string
-	OllamaURL        string
-	OllamaModel      string
-	OllamaEmbedModel string
-	QdrantURL        string
-	QdrantCol        string
-	EmbedDim         int
-	DocsDir          string
-	KnowledgeDir     string
-	ChunkSize        int
-	ChunkOverlap     int
+	APIAddr              string
+	OllamaURL            string
+	OllamaModel          string
+	OllamaEmbedModel     string
+	OllamaThink          string // 请求是否带 think 参数：true / false，留空不传
+	LLMProvider          string // 聊天模型服务：ollama / openai（OpenAI 兼容 API）
+	EmbedProvider        string // Embedding 服务：ollama / openai
+	OpenAIURL            string // OpenAI 兼容 API 地址，含 /v1
+	OpenAIKey            string // 可选的 API key
+	OpenAIModel          string // OpenAI 兼容服务上的聊天模型名
+	OpenAIEmbedModel     string // OpenAI 兼容服务上的 Embedding 模型名
+	VectorStore          string // 向量库后端：qdrant / embedded
+	EmbeddedStoreDir     string // embedded 后端的数据目录
+	QdrantURL            string
+	QdrantCol            string // 集合名，embedded 后端用作文件名
+	EmbedDim             int
+	EmbedMismatch        string // 模型与已有集合不一致时：fail 报错退出 / migrate 改用新集合
+	DocsDir              string
+	KnowledgeDir         string
+	ChunkSize            int
+	ChunkOverlap         int
+	ChunkStrategy        string        // rune / sentence / paragraph / token / semantic
+	IngestManifest       string        // 增量导入清单（记录已导入文件的哈希与修改时间）
+	IngestInclude        []string      // 只导入匹配的文件（glob，支持 **）
+	IngestExclude        []string      // 跳过匹配的文件或目录
+	IngestFollowSymlinks bool          // 是否跟随符号链接
+	IngestIncludeHidden  bool          // 是否导入以 . 开头的隐藏文件
+	SkipBootIngest       bool          // API 启动时跳过知识库导入（改用 cmd/ingest）
+	IngestWorkers        int           // 并发处理文件的 worker 数
+	EmbedBatchSize       int           // 单次 /api/embed 请求的切片数
+	EmbedConcurrency     int           // 同时在途的 Embedding 请求数
+	UpsertBatchSize      int           // 单次 Qdrant upsert 的点数
+	IngestRetries        int           // 单个文件暂时性失败后的重试次数
+	IngestJobDir         string        // 后台任务记录的持久化目录
+	IngestTimeout        time.Duration // 启动导入的超时，0 表示不限
+	AllowOrigins         []string      // 允许的前端来源（CORS 与 WebSocket 共用）
+	ConversationDir      string        // 会话持久化目录，留空则只存内存
+	HistoryBudget        int           // 回放历史消息的 token 预算
+	AdminToken           string        // 管理接口的 Bearer token，留空则关闭管理接口
+	MaxUploadMB          int           // 单个上传文件的大小上限（MB）
+	RetrievalMode        string        // vector / keyword / hybrid
+	KeywordIndex         string        // BM25 关键词索引文件
+	HybridCandidates     int           // hybrid 模式下每一路召回的候选数
+	RRFK                 int           // RRF 融合的平滑常数 k
+	Reranker             string        // none / mmr / cross-encoder / llm，可被请求覆盖
+	RerankCandidates     int           // 启用重排时召回的候选数
+	RerankModel          string        // cross-encoder 打分使用的生成模型，不能是推理模型
+	MMRLambda            float64       // MMR 中相关性的权重，越小越强调多样性
+	MinScore             float64       // 片段进入 prompt 的最低向量相似度（keyword 模式为查询词命中比例），0 表示不限
+	NoContextMode        string        // 没有达到阈值的片段时：general（通用知识作答并声明）/ refuse（拒答）
+	QueryExpansion       []string      // 检索前的查询扩展：rewrite / multi / hyde 的组合，留空不扩展
+	QueryParaphrases     int           // multi 扩展生成的同义问法数
+	CondenseTurns        int           // 多轮对话中改写追问时参考的最近轮数，0 表示不改写
 }
 
 func LoadConfig() *Config {
@@ -32,25 +76,120 @@ func LoadConfig() *Config {
 	viper.SetDefault("OLLAMA_BASE_URL", "http://localhost:11434")
 	viper.SetDefault("OLLAMA_MODEL", "deepseek-r1:14b")
 	viper.SetDefault("OLLAMA_EMBED_MODEL", "mxbai-embed-large")
+	viper.SetDefault("OLLAMA_THINK", "")
+	viper.SetDefault("LLM_PROVIDER", "ollama")
+	viper.SetDefault("EMBED_PROVIDER", "ollama")
+	viper.SetDefault("OPENAI_BASE_URL", "http://localhost:8000/v1")
+	viper.SetDefault("OPENAI_API_KEY", "")
+	viper.SetDefault("OPENAI_MODEL", "")
+	viper.SetDefault("OPENAI_EMBED_MODEL", "")
+	viper.SetDefault("VECTOR_STORE", "qdrant")
+	viper.SetDefault("EMBEDDED_STORE_DIR", "./data/vectors")
 	viper.SetDefault("QDRANT_URL", "http://localhost:6333")
 	viper.SetDefault("QDRANT_COLLECTION", "physics")
 	viper.SetDefault("EMBED_DIM", 1024)
+	viper.SetDefault("EMBED_MISMATCH", "fail")
 	viper.SetDefault("KNOWLEDGE_DIR", "./knowledge")
 	viper.SetDefault("DOCS_DIR", "./docs")
 	viper.SetDefault("CHUNK_SIZE", 500)
 	viper.SetDefault("CHUNK_OVERLAP", 50)
+	viper.SetDefault("CHUNK_STRATEGY", "rune")
+	viper.SetDefault("INGEST_MANIFEST", "./data/ingest_manifest.json")
+	viper.SetDefault("INGEST_INCLUDE", "")
+	viper.SetDefault("INGEST_EXCLUDE", "")
+	viper.SetDefault("INGEST_FOLLOW_SYMLINKS", false)
+	viper.SetDefault("INGEST_INCLUDE_HIDDEN", false)
+	viper.SetDefault("SKIP_BOOT_INGEST", false)
+	viper.SetDefault("INGEST_WORKERS", 2)
+	viper.SetDefault("EMBED_BATCH_SIZE", 32)
+	viper.SetDefault("EMBED_CONCURRENCY", 1)
+	viper.SetDefault("UPSERT_BATCH_SIZE", 256)
+	viper.SetDefault("INGEST_MAX_RETRIES", 3)
+	viper.SetDefault("INGEST_JOB_DIR", "./data/jobs")
+	viper.SetDefault("INGEST_TIMEOUT", "10m")
+	viper.SetDefault("CORS_ORIGINS", "http://localhost:5173")
+	viper.SetDefault("CONVERSATION_DIR", "./data/conversations")
+	viper.SetDefault("HISTORY_TOKEN_BUDGET", 2048)
+	viper.SetDefault("ADMIN_TOKEN", "")
+	viper.SetDefault("MAX_UPLOAD_MB", 50)
+	viper.SetDefault("RETRIEVAL_MODE", "hybrid")
+	viper.SetDefault("KEYWORD_INDEX", "./data/keyword_index.json")
+	viper.SetDefault("HYBRID_CANDIDATES", 20)
+	viper.SetDefault("RRF_K", 60)
+	viper.SetDefault("RERANKER", "none")
+	viper.SetDefault("RERANK_CANDIDATES", 30)
+	viper.SetDefault("RERANK_MODEL", "qwen2.5:3b")
+	viper.SetDefault("MMR_LAMBDA", 0.7)
+	viper.SetDefault("MIN_SCORE", 0.5)
+	viper.SetDefault("NO_CONTEXT_MODE", "general")
+	viper.SetDefault("QUERY_EXPANSION", "")
+	viper.SetDefault("QUERY_PARAPHRASES", 3)
+	viper.SetDefault("CONDENSE_TURNS", 3)
 
 	return &Config{
-		APIAddr:          viper.GetString("API_ADDR"),
-		OllamaURL:        viper.GetString("OLLAMA_BASE_URL"),
-		OllamaModel:      viper.GetString("OLLAMA_MODEL"),
-		OllamaEmbedModel: viper.GetString("OLLAMA_EMBED_MODEL"),
-		QdrantURL:        viper.GetString("QDRANT_URL"),
-		QdrantCol:        viper.GetString("QDRANT_COLLECTION"),
-		EmbedDim:         viper.GetInt("EMBED_DIM"),
-		DocsDir:          viper.GetString("DOCS_DIR"),
-		KnowledgeDir:     viper.GetString("KNOWLEDGE_DIR"),
-		ChunkSize:        viper.GetInt("CHUNK_SIZE"),
-		ChunkOverlap:     viper.GetInt("CHUNK_OVERLAP"),
+		APIAddr:              viper.GetString("API_ADDR"),
+		OllamaURL:            viper.GetString("OLLAMA_BASE_URL"),
+		OllamaModel:          viper.GetString("OLLAMA_MODEL"),
+		OllamaEmbedModel:     viper.GetString("OLLAMA_EMBED_MODEL"),
+		OllamaThink:          viper.GetString("OLLAMA_THINK"),
+		LLMProvider:          viper.GetString("LLM_PROVIDER"),
+		EmbedProvider:        viper.GetString("EMBED_PROVIDER"),
+		OpenAIURL:            viper.GetString("OPENAI_BASE_URL"),
+		OpenAIKey:            viper.GetString("OPENAI_API_KEY"),
+		OpenAIModel:          viper.GetString("OPENAI_MODEL"),
+		OpenAIEmbedModel:     viper.GetString("OPENAI_EMBED_MODEL"),
+		VectorStore:          viper.GetString("VECTOR_STORE"),
+		EmbeddedStoreDir:     viper.GetString("EMBEDDED_STORE_DIR"),
+		QdrantURL:            viper.GetString("QDRANT_URL"),
+		QdrantCol:            viper.GetString("QDRANT_COLLECTION"),
+		EmbedDim:             viper.GetInt("EMBED_DIM"),
+		EmbedMismatch:        viper.GetString("EMBED_MISMATCH"),
+		DocsDir:              viper.GetString("DOCS_DIR"),
+		KnowledgeDir:         viper.GetString("KNOWLEDGE_DIR"),
+		ChunkSize:            viper.GetInt("CHUNK_SIZE"),
+		ChunkOverlap:         viper.GetInt("CHUNK_OVERLAP"),
+		ChunkStrategy:        viper.GetString("CHUNK_STRATEGY"),
+		IngestManifest:       viper.GetString("INGEST_MANIFEST"),
+		IngestInclude:        splitList(viper.GetString("INGEST_INCLUDE")),
+		IngestExclude:        splitList(viper.GetString("INGEST_EXCLUDE")),
+		IngestFollowSymlinks: viper.GetBool("INGEST_FOLLOW_SYMLINKS"),
+		IngestIncludeHidden:  viper.GetBool("INGEST_INCLUDE_HIDDEN"),
+		SkipBootIngest:       viper.GetBool("SKIP_BOOT_INGEST"),
+		IngestWorkers:        viper.GetInt("INGEST_WORKERS"),
+		EmbedBatchSize:       viper.GetInt("EMBED_BATCH_SIZE"),
+		EmbedConcurrency:     viper.GetInt("EMBED_CONCURRENCY"),
+		UpsertBatchSize:      viper.GetInt("UPSERT_BATCH_SIZE"),
+		IngestRetries:        viper.GetInt("INGEST_MAX_RETRIES"),
+		IngestJobDir:         viper.GetString("INGEST_JOB_DIR"),
+		IngestTimeout:        viper.GetDuration("INGEST_TIMEOUT"),
+		AllowOrigins:         splitList(viper.GetString("CORS_ORIGINS")),
+		ConversationDir:      viper.GetString("CONVERSATION_DIR"),
+		HistoryBudget:        viper.GetInt("HISTORY_TOKEN_BUDGET"),
+		AdminToken:           viper.GetString("ADMIN_TOKEN"),
+		MaxUploadMB:          viper.GetInt("MAX_UPLOAD_MB"),
+		RetrievalMode:        viper.GetString("RETRIEVAL_MODE"),
+		KeywordIndex:         viper.GetString("KEYWORD_INDEX"),
+		HybridCandidates:     viper.GetInt("HYBRID_CANDIDATES"),
+		RRFK:                 viper.GetInt("RRF_K"),
+		Reranker:             viper.GetString("RERANKER"),
+		RerankCandidates:     viper.GetInt("RERANK_CANDIDATES"),
+		RerankModel:          viper.GetString("RERANK_MODEL"),
+		MMRLambda:            viper.GetFloat64("MMR_LAMBDA"),
+		MinScore:             viper.GetFloat64("MIN_SCORE"),
+		NoContextMode:        viper.GetString("NO_CONTEXT_MODE"),
+		QueryExpansion:       splitList(viper.GetString("QUERY_EXPANSION")),
+		QueryParaphrases:     viper.GetInt("QUERY_PARAPHRASES"),
+		CondenseTurns:        viper.GetInt("CONDENSE_TURNS"),
 	}
 }
+
+// splitList 把逗号分隔的环境变量拆成切片，忽略空项
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,194 @@
+package conversation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iammm0/physics-llm/internal/llm"
+)
+
+// ErrNotFound 会话不存在
+var ErrNotFound = errors.New("conversation not found")
+
+// Turn 会话中的一条消息，Role 为 user / assistant
+type Turn struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Query     string    `json:"query,omitempty"`     // 仅 user 消息：结合上文改写出的独立检索问题，与 Content 相同时省略
+	Reasoning string    `json:"reasoning,omitempty"` // 仅 assistant 消息：推理模型的思考过程，不回放给模型
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Conversation 一次完整会话
+type Conversation struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Turns     []Turn    `json:"turns"`
+}
+
+// Summary 列表接口用的精简视图
+type Summary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	TurnCount int       `json:"turn_count"`
+}
+
+// Store 内存保存会话，dir 非空时每个会话另存为 dir/{id}.json，重启后自动加载
+type Store struct {
+	mu    sync.RWMutex
+	dir   string
+	convs map[string]*Conversation
+}
+
+// NewStore 创建会话存储；dir 为空则只保存在内存中
+func NewStore(dir string) (*Store, error) {
+	s := &Store{dir: dir, convs: map[string]*Conversation{}}
+	if dir == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建会话目录失败: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var c Conversation
+		if err := json.Unmarshal(b, &c); err != nil {
+			return nil, fmt.Errorf("解析会话文件 %s 失败: %w", f, err)
+		}
+		s.convs[c.ID] = &c
+	}
+	return s, nil
+}
+
+// Create 新建空会话
+func (s *Store) Create(title string) (*Conversation, error) {
+	now := time.Now()
+	c := &Conversation{
+		ID:        uuid.New().String(),
+		Title:     title,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(c); err != nil {
+		return nil, err
+	}
+	s.convs[c.ID] = c
+	return c.clone(), nil
+}
+
+// List 按最近更新时间倒序返回所有会话摘要
+func (s *Store) List() []Summary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Summary, 0, len(s.convs))
+	for _, c := range s.convs {
+		out = append(out, Summary{
+			ID:        c.ID,
+			Title:     c.Title,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			TurnCount: len(c.Turns),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out
+}
+
+// Get 返回会话副本，调用方可随意修改
+func (s *Store) Get(id string) (*Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.convs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return c.clone(), nil
+}
+
+// Delete 删除会话及其持久化文件
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.convs[id]; !ok {
+		return ErrNotFound
+	}
+	if s.dir != "" {
+		if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	delete(s.convs, id)
+	return nil
+}
+
+// Append 追加若干条消息；标题为空时取第一条用户消息的前 30 个字
+func (s *Store) Append(id string, turns ...Turn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.convs[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	for i := range turns {
+		if turns[i].CreatedAt.IsZero() {
+			turns[i].CreatedAt = now
+		}
+		if c.Title == "" && turns[i].Role == "user" {
+			c.Title = llm.TruncateRunes(strings.TrimSpace(turns[i].Content), 30)
+		}
+	}
+	c.Turns = append(c.Turns, turns...)
+	c.UpdatedAt = now
+	return s.save(c)
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// save 调用方需持有写锁；先写临时文件再 rename，避免半截文件
+func (s *Store) save(c *Conversation) error {
+	if s.dir == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path(c.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(c.ID))
+}
+
+func (c *Conversation) clone() *Conversation {
+	cp := *c
+	cp.Turns = append([]Turn(nil), c.Turns...)
+	return &cp
+}
@@ -0,0 +1,24 @@
+package conversation
+
+import "github.com/iammm0/physics-llm/internal/llm"
+
+// Window 从最新的消息往前取，直到累计 token 超出 budget 为止，按时间顺序返回。
+// 以"用户提问 + 助手回答"为单位整轮丢弃，保证返回的历史总是从 user 消息开始；
+// budget <= 0 表示不限制。
+func (c *Conversation) Window(budget int) []Turn {
+	if budget <= 0 {
+		return append([]Turn(nil), c.Turns...)
+	}
+
+	start, used := len(c.Turns), 0
+	for i := len(c.Turns) - 1; i >= 0; i-- {
+		used += llm.EstimateTokens(c.Turns[i].Content)
+		if used > budget {
+			break
+		}
+		if c.Turns[i].Role == "user" {
+			start = i
+		}
+	}
+	return append([]Turn(nil), c.Turns[start:]...)
+}
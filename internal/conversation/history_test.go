@@ -0,0 +1,43 @@
+package conversation
+
+import "testing"
+
+func TestWindow(t *testing.T) {
+	// 汉字各算 1 个 token
+	turns := []Turn{
+		{Role: "user", Content: "一二"},
+		{Role: "assistant", Content: "三四五"},
+		{Role: "user", Content: "六七"},
+		{Role: "assistant", Content: "八九十"},
+	}
+	tests := []struct {
+		name   string
+		turns  []Turn
+		budget int
+		want   int // 保留的最近消息数
+	}{
+		{"不限制", turns, 0, 4},
+		{"预算充足", turns, 100, 4},
+		{"恰好容纳全部", turns, 10, 4},
+		{"只容纳最后一轮", turns, 5, 2},
+		{"半轮不保留", turns, 8, 2},
+		{"一轮都放不下", turns, 2, 0},
+		{"最后一条是未回答的提问", turns[:3], 2, 1},
+		{"空会话", nil, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Conversation{Turns: tt.turns}
+			got := c.Window(tt.budget)
+			if len(got) != tt.want {
+				t.Fatalf("Window(%d) 返回 %d 条，want %d", tt.budget, len(got), tt.want)
+			}
+			if len(got) > 0 && got[0].Role != "user" {
+				t.Errorf("窗口应从 user 消息开始，got %q", got[0].Role)
+			}
+			if tail := tt.turns[len(tt.turns)-len(got):]; len(got) > 0 && got[0].Content != tail[0].Content {
+				t.Errorf("应保留最近的消息，got %q", got[0].Content)
+			}
+		})
+	}
+}
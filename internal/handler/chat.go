@@ -2,14 +2,21 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
 	"github.com/iammm0/physics-llm/internal/config"
-	"github.com/iammm0/physics-llm/internal/ollama"
+	"github.com/iammm0/physics-llm/internal/conversation"
+	"github.com/iammm0/physics-llm/internal/ingest"
+	"github.com/iammm0/physics-llm/internal/llm"
+	"github.com/iammm0/physics-llm/internal/llm/provider"
+	"github.com/iammm0/physics-llm/internal/retrieval"
 	"github.com/iammm0/physics-llm/internal/store"
 )
 
@@ -20,69 +27,361 @@ const (
 	// ContextSeparator 文档片段间分隔符
 	ContextSeparator = "\n---\n"
 
+	// RetrieveTimeout Embedding + 检索阶段的超时
+	RetrieveTimeout = 30 * time.Second
+
 	// 系统指令：说明模型的部署背景、目标受众、维护团队、主导开发者等
 	systemPrompt = `你是运行在天津城建大学私人服务器上的 Physics-LLM，基于 Deepseek 本地模型部署，
 由天津城建大学理学院物理研究社研发并维护。主导开发者为 22 级应用物理学专业 1 班赵明俊。
 你的使命是帮助天津城建大学范围内的本科生和研究生解答物理问题，检索并总结相关课程资料与文档，
 以严谨、准确的专业语言输出。回答中必要时可引用文献、课程名称或具体章节。`
 
-	// 用户模板：首先给出检索到的文档片段，再让模型作答
-	userPromptTmpl = `以下是与用户问题相关的文档片段（已按相关度排序，最多取前 %d 条）：
+	// 用户模板：首先给出编号后的文档片段，再让模型作答并用编号标注引用
+	userPromptTmpl = `以下是与用户问题相关的文档片段（已按相关度排序，最多取前 %d 条，每条以 [编号] 开头）：
 %s
 
-请基于上述内容，并结合你的物理学专业知识，详细回答下面的问题：
+请基于上述内容，并结合你的物理学专业知识，详细回答下面的问题。
+凡是用到某个片段的内容，请在对应句末用方括号标注其编号，如 [1] 或 [1,2]；不要编造不存在的编号。
+“%s”`
+
+	// 没有检索到相关资料时的用户模板：只凭通用知识作答，不得伪造引用
+	noContextPromptTmpl = `知识库中没有检索到与下面问题相关的资料。
+请仅凭你的物理学专业知识回答，不要标注 [编号] 引用，也不要声称内容出自课程资料或文档；拿不准的地方请明确说明。
 “%s”`
+
+	// noContextDisclaimer general 模式下加在回答开头的免责声明
+	noContextDisclaimer = "（知识库中没有找到与该问题相关的资料，以下回答基于通用物理知识，未经课程资料核对，仅供参考。）\n\n"
+
+	// noContextRefusal refuse 模式下的固定回答
+	noContextRefusal = "抱歉，知识库中没有找到与该问题相关的资料，暂时无法回答。可以换个说法再问，或联系管理员补充相关文档。"
+)
+
+// 没有达到相关度阈值的片段时的处理方式（NO_CONTEXT_MODE）
+const (
+	NoContextGeneral = "general" // 凭通用知识作答，回答开头加免责声明
+	NoContextRefuse  = "refuse"  // 不调用模型，直接拒答
 )
 
 type ChatRequest struct {
-	Query string `json:"query" binding:"required"`
+	Query          string        `json:"query" binding:"required"`
+	ConversationID string        `json:"conversation_id"`                                               // 可选；携带时回放该会话历史并把本轮写回
+	Rerank         string        `json:"rerank" binding:"omitempty,oneof=none mmr cross-encoder llm"`   // 可选；覆盖 RERANKER 配置
+	Filter         *store.Filter `json:"filter"`                                                        // 可选；只在满足条件的片段中检索
+	Expand         []string      `json:"expand" binding:"omitempty,dive,oneof=none rewrite multi hyde"` // 可选；覆盖 QUERY_EXPANSION 配置
+}
+
+// options 请求对应的检索参数
+func (r ChatRequest) options() retrieval.Options {
+	return retrieval.Options{TopK: DefaultTopK, Rerank: r.Rerank, Filter: r.Filter}
+}
+
+// validate 校验字段取值与过滤条件；/v1/ws 的帧不经过 gin 绑定，三个入口都在检索前调用
+func (r *ChatRequest) validate() error {
+	if err := binding.Validator.ValidateStruct(r); err != nil {
+		return err
+	}
+	return r.Filter.Validate()
 }
 
 type ChatResponse struct {
-	Response string `json:"response"`
+	Response       string   `json:"response"`
+	Reasoning      string   `json:"reasoning,omitempty"` // 推理模型（如 deepseek-r1）的思考过程，已从 response 中剥离
+	Sources        []Source `json:"sources"`
+	Grounded       bool     `json:"grounded"` // false 表示没有达到相关度阈值的资料，回答不基于知识库
+	ConversationID string   `json:"conversation_id,omitempty"`
+	Debug          *Debug   `json:"debug,omitempty"`
 }
 
-// RegisterRoutes 挂载 /v1/chat
-func RegisterRoutes(r *gin.Engine, cfg *config.Config) {
-	llm := ollama.NewClient(cfg)
-	db := store.NewClient(cfg)
+// Debug 检索过程的中间结果，便于排查召回效果；没有可报告的内容时省略
+type Debug struct {
+	CondensedQuery string               `json:"condensed_query,omitempty"` // 结合对话历史改写出的独立检索问题
+	Expansion      *retrieval.Expansion `json:"expansion,omitempty"`       // 查询扩展（改写、同义问法、HyDE）
+}
 
-	r.POST("/v1/chat", func(c *gin.Context) {
-		var req ChatRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
+// api 持有各路由共享的客户端
+type api struct {
+	model         llm.ChatModel
+	retriever     *retrieval.Retriever
+	convs         *conversation.Store
+	historyBudget int
+	upgrader      websocket.Upgrader
+	minScore      float32 // 片段进入 prompt 的最低向量相似度
+	noContext     string  // 见 NoContextGeneral / NoContextRefuse
+
+	// 知识库管理
+	ingester     *ingest.Ingester
+	jobs         *ingest.Jobs
+	knowledgeDir string
+	maxUpload    int64
+}
+
+// RegisterRoutes 挂载聊天（/v1/chat、/v1/chat/stream、/v1/ws）、检索（/v1/search）、会话管理与知识库管理路由
+func RegisterRoutes(r *gin.Engine, cfg *config.Config) error {
+	switch cfg.NoContextMode {
+	case NoContextGeneral, NoContextRefuse:
+	default:
+		return fmt.Errorf("未知的 NO_CONTEXT_MODE: %s", cfg.NoContextMode)
+	}
+	convs, err := conversation.NewStore(cfg.ConversationDir)
+	if err != nil {
+		return err
+	}
+	ingester, err := ingest.New(cfg)
+	if err != nil {
+		return err
+	}
+	jobs, err := ingest.NewJobs(ingester, cfg.IngestJobDir)
+	if err != nil {
+		return err
+	}
+	jobs.Start(context.Background())
+	chat, err := provider.NewChatModel(cfg)
+	if err != nil {
+		return err
+	}
+	embedder, err := provider.NewEmbedder(cfg)
+	if err != nil {
+		return err
+	}
+	retriever, err := retrieval.New(cfg, chat, embedder, ingester.Store(), ingester.Keywords())
+	if err != nil {
+		return err
+	}
+
+	h := &api{
+		model:         chat,
+		retriever:     retriever,
+		convs:         convs,
+		historyBudget: cfg.HistoryBudget,
+		upgrader:      newUpgrader(cfg.AllowOrigins),
+		minScore:      float32(cfg.MinScore),
+		noContext:     cfg.NoContextMode,
+		ingester:      ingester,
+		jobs:          jobs,
+		knowledgeDir:  cfg.KnowledgeDir,
+		maxUpload:     int64(cfg.MaxUploadMB) << 20,
+	}
+
+	r.POST("/v1/chat", h.chat)
+	r.POST("/v1/chat/stream", h.chatStream)
+	r.GET("/v1/ws", h.chatWS)
+	r.GET("/v1/search", h.searchGet)
+	r.POST("/v1/search", h.search)
+
+	// 会话 ID 只有创建者知道，按 ID 读写无需鉴权；列出全部会话属于管理操作
+	r.POST("/v1/conversations", h.createConversation)
+	r.GET("/v1/conversations/:id", h.getConversation)
+	r.DELETE("/v1/conversations/:id", h.deleteConversation)
+
+	admin := r.Group("/v1", adminAuth(cfg.AdminToken))
+	admin.GET("/conversations", h.listConversations)
+	admin.GET("/documents", h.listDocuments)
+	admin.POST("/documents", h.uploadDocument)
+	admin.DELETE("/documents/:id", h.deleteDocument)
+	admin.POST("/documents/:id/reindex", h.reindexDocument)
+	admin.POST("/ingest/sync", h.syncKnowledge)
+	admin.GET("/ingest/jobs", h.listJobs)
+	admin.GET("/ingest/jobs/:id", h.getJob)
+	return nil
+}
+
+// grounding 一次检索的结果：发给模型的本轮 prompt 与编号后的来源
+type grounding struct {
+	query    string // 实际用于检索的问题；有历史时为改写后的独立问题
+	prompt   string
+	sources  []Source
+	grounded bool   // 是否有达到相关度阈值的片段
+	debug    *Debug // 没有可报告的内容时为 nil
+}
+
+/*
+retrieve 检索并重排出 opt.TopK 个达到 MIN_SCORE 的文档片段；一个都没有时返回 grounded=false 的结果
+
+有对话历史时先把追问改写成独立问题（见 CONDENSE_TURNS），再按 expand 扩展查询；
+改写和扩展只影响检索，prompt 中仍是用户的原始问题。
+*/
+func (h *api) retrieve(ctx context.Context, query string, history []llm.Message, opt retrieval.Options, expand []string) (grounding, error) {
+	if opt.MinScore == 0 {
+		opt.MinScore = h.minScore
+	}
+	debug := &Debug{}
+
+	// 1) 结合历史改写追问，如"那第二步呢？"
+	search, err := h.retriever.Condense(ctx, history, query)
+	if err != nil {
+		return grounding{}, err
+	}
+	if search != query {
+		debug.CondensedQuery = search
+	}
+
+	// 2) 可选的查询扩展：改写 / 同义问法 / HyDE，见 QUERY_EXPANSION
+	exp, err := h.retriever.Expand(ctx, search, expand)
+	if err != nil {
+		return grounding{}, err
+	}
+	opt.Expansion = exp
+	debug.Expansion = exp
+	if *debug == (Debug{}) {
+		debug = nil
+	}
+
+	// 3) 召回候选（向量 / 关键词 / 混合，见 RETRIEVAL_MODE）并重排出 topK
+	hits, err := h.retriever.Retrieve(ctx, search, opt)
+	if err != nil {
+		return grounding{}, err
+	}
+	if len(hits) == 0 {
+		return grounding{query: search, prompt: fmt.Sprintf(noContextPromptTmpl, query), sources: []Source{}, debug: debug}, nil
+	}
 
-		// 超时控制
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
-		defer cancel()
+	// 4) 组装用户 prompt
+	combined, sources := numberHits(hits)
+	return grounding{
+		query:    search,
+		prompt:   fmt.Sprintf(userPromptTmpl, opt.TopK, combined, query),
+		sources:  sources,
+		grounded: true,
+		debug:    debug,
+	}, nil
+}
+
+/*
+generate 按检索结果生成回答，fn 不为 nil 时流式调用模型并逐段回调
 
-		// 1) 生成用户 Query 的向量
-		vec, err := llm.Embeddings(req.Query)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成 Embedding 失败: " + err.Error()})
-			return
+没有相关资料时按 NO_CONTEXT_MODE 处理：refuse 不调用模型，直接以固定文案作答；
+general 让模型凭通用知识作答，并在回答开头加上免责声明。两种情况的文案都会经 fn 推给客户端，
+返回值即完整回答（推理过程已分离到 Reasoning），可直接写入会话。
+*/
+func (h *api) generate(ctx context.Context, history []llm.Message, g grounding, fn llm.StreamFunc) (llm.Reply, error) {
+	var prefix string
+	if !g.grounded {
+		if h.noContext == NoContextRefuse {
+			if fn != nil {
+				if err := fn(llm.Delta{Content: noContextRefusal}); err != nil {
+					return llm.Reply{}, err
+				}
+			}
+			return llm.Reply{Content: noContextRefusal}, nil
 		}
+		prefix = noContextDisclaimer
+	}
 
-		// 2) 检索 topK 文档片段
-		docs, err := db.Search(ctx, vec, DefaultTopK)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "检索文档失败: " + err.Error()})
-			return
+	msgs := buildMessages(history, g.prompt)
+	var (
+		reply llm.Reply
+		err   error
+	)
+	if fn == nil {
+		reply, err = h.model.Chat(ctx, msgs)
+	} else {
+		if prefix != "" {
+			if err := fn(llm.Delta{Content: prefix}); err != nil {
+				return llm.Reply{}, err
+			}
 		}
+		reply, err = h.model.ChatStream(ctx, msgs, fn)
+	}
+	reply.Content = prefix + reply.Content
+	return reply, err
+}
 
-		// 3) 组装用户 prompt
-		combined := strings.Join(docs, ContextSeparator)
-		userPrompt := fmt.Sprintf(userPromptTmpl, combined, req.Query)
+// history 读取会话历史并按 token 预算截断；convID 为空时返回 nil
+func (h *api) history(convID string) ([]llm.Message, error) {
+	if convID == "" {
+		return nil, nil
+	}
+	conv, err := h.convs.Get(convID)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []llm.Message
+	for _, t := range conv.Window(h.historyBudget) {
+		msgs = append(msgs, llm.Message{Role: t.Role, Content: t.Content})
+	}
+	return msgs, nil
+}
 
-		// 4) 调用 Ollama
-		answer, err := llm.Complete(userPrompt, systemPrompt)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "调用模型失败: " + err.Error()})
-			return
-		}
+// record 把本轮原始问题与回答写回会话，search 为实际检索用的问题；历史里不存检索片段，避免反复塞进上下文。
+// 推理过程随回答保存便于查看，但 history 回放时只取 Content
+func (h *api) record(convID, query, search string, reply llm.Reply) error {
+	if convID == "" {
+		return nil
+	}
+	user := conversation.Turn{Role: "user", Content: query}
+	if search != query {
+		user.Query = search
+	}
+	return h.convs.Append(convID, user, conversation.Turn{Role: "assistant", Content: reply.Content, Reasoning: reply.Reasoning})
+}
+
+// buildMessages 按 system → 历史 → 本轮 prompt 的顺序组装 messages
+func buildMessages(history []llm.Message, userPrompt string) []llm.Message {
+	msgs := make([]llm.Message, 0, len(history)+2)
+	msgs = append(msgs, llm.Message{Role: "system", Content: systemPrompt})
+	msgs = append(msgs, history...)
+	return append(msgs, llm.Message{Role: "user", Content: userPrompt})
+}
+
+// historyStatus 会话不存在返回 404，其余视为服务端错误
+func historyStatus(err error) int {
+	if errors.Is(err, conversation.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *api) chat(c *gin.Context) {
+	// 客户端声明接收事件流时走 SSE
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		h.chatStream(c)
+		return
+	}
+
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := h.history(req.ConversationID)
+	if err != nil {
+		c.JSON(historyStatus(err), gin.H{"error": "读取会话失败: " + err.Error()})
+		return
+	}
+
+	// 超时控制
+	ctx, cancel := context.WithTimeout(c.Request.Context(), RetrieveTimeout)
+	defer cancel()
+
+	g, err := h.retrieve(ctx, req.Query, history, req.options(), req.Expand)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 4) 调用模型；生成阶段跟随请求上下文，客户端断开即取消
+	reply, err := h.generate(c.Request.Context(), history, g, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "调用模型失败: " + err.Error()})
+		return
+	}
+
+	if err := h.record(req.ConversationID, req.Query, g.query, reply); err != nil {
+		c.JSON(historyStatus(err), gin.H{"error": "保存会话失败: " + err.Error()})
+		return
+	}
 
-		c.JSON(http.StatusOK, ChatResponse{Response: answer})
+	c.JSON(http.StatusOK, ChatResponse{
+		Response:       reply.Content,
+		Reasoning:      reply.Reasoning,
+		Sources:        markCited(reply.Content, g.sources),
+		Grounded:       g.grounded,
+		ConversationID: req.ConversationID,
+		Debug:          g.debug,
 	})
 }
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createConversationRequest struct {
+	Title string `json:"title"` // 可选；为空时取第一个问题
+}
+
+// createConversation 处理 POST /v1/conversations
+func (h *api) createConversation(c *gin.Context) {
+	var req createConversationRequest
+	// 允许空请求体
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	conv, err := h.convs.Create(req.Title)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建会话失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, conv)
+}
+
+// listConversations 处理 GET /v1/conversations
+func (h *api) listConversations(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"conversations": h.convs.List()})
+}
+
+// getConversation 处理 GET /v1/conversations/:id
+func (h *api) getConversation(c *gin.Context) {
+	conv, err := h.convs.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(historyStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, conv)
+}
+
+// deleteConversation 处理 DELETE /v1/conversations/:id
+func (h *api) deleteConversation(c *gin.Context) {
+	if err := h.convs.Delete(c.Param("id")); err != nil {
+		c.JSON(historyStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
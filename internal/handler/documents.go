@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iammm0/physics-llm/internal/ingest"
+)
+
+// Document 知识库中已导入的文件
+type Document struct {
+	ID         string    `json:"id"`
+	Source     string    `json:"source"`
+	Chunks     int       `json:"chunks"`
+	Size       int64     `json:"size"`
+	IngestedAt time.Time `json:"ingested_at"`
+}
+
+// findDocument 按文档 ID 在清单中查找 source
+func (h *api) findDocument(id string) (string, ingest.FileState, bool) {
+	for source, st := range h.ingester.Manifest().Snapshot() {
+		if ingest.DocumentID(source) == id {
+			return source, st, true
+		}
+	}
+	return "", ingest.FileState{}, false
+}
+
+// listDocuments 处理 GET /v1/documents
+func (h *api) listDocuments(c *gin.Context) {
+	files := h.ingester.Manifest().Snapshot()
+	docs := make([]Document, 0, len(files))
+	for source, st := range files {
+		docs = append(docs, Document{
+			ID:         ingest.DocumentID(source),
+			Source:     source,
+			Chunks:     st.Chunks,
+			Size:       st.Size,
+			IngestedAt: st.IngestedAt,
+		})
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Source < docs[j].Source })
+	c.JSON(http.StatusOK, gin.H{"documents": docs})
+}
+
+/*
+uploadDocument 处理 POST /v1/documents（multipart/form-data）
+
+	file       必填，上传的文件；扩展名须能被提取器注册表或纯文本读取处理，不能是 _meta.yaml / *.meta.yaml 旁注
+	dir        可选，知识库内的子目录，如 optics/lab3
+	overwrite  可选，"true" 时覆盖同名文件
+
+文件保存到知识库目录后提交后台导入任务，返回 202 与任务信息。
+*/
+func (h *api) uploadDocument(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUpload)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败: " + err.Error()})
+		return
+	}
+
+	name := filepath.Base(fh.Filename)
+	if name == "." || strings.HasPrefix(name, ".") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "非法文件名"})
+		return
+	}
+	if ingest.IsSidecar(name) {
+		// 旁注只影响同目录文件的标签，单独上传不会触发这些文件重导
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能上传元数据旁注文件: " + name})
+		return
+	}
+	if !ingest.Supported(name) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "不支持的文件类型: " + filepath.Ext(name)})
+		return
+	}
+
+	dir := path.Clean("/" + filepath.ToSlash(c.PostForm("dir")))[1:] // 去掉 .. 与开头的 /
+	source := path.Join(dir, name)
+	dst := filepath.Join(h.knowledgeDir, filepath.FromSlash(source))
+
+	if _, err := os.Stat(dst); err == nil && c.PostForm("overwrite") != "true" {
+		c.JSON(http.StatusConflict, gin.H{"error": "文件已存在: " + source})
+		return
+	}
+	if err := c.SaveUploadedFile(fh, dst); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败: " + err.Error()})
+		return
+	}
+
+	job, err := h.jobs.Submit("upload", source, func() ([]ingest.PlanItem, error) {
+		return h.ingester.PlanPath(dst, false)
+	})
+	jobAccepted(c, gin.H{"id": ingest.DocumentID(source), "source": source}, job, err)
+}
+
+// deleteDocument 处理 DELETE /v1/documents/:id：立即删除磁盘文件，向量与清单记录由后台任务清理
+func (h *api) deleteDocument(c *gin.Context) {
+	source, _, ok := h.findDocument(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文档不存在"})
+		return
+	}
+	file := filepath.Join(h.knowledgeDir, filepath.FromSlash(source))
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除文件失败: " + err.Error()})
+		return
+	}
+	job, err := h.jobs.Submit("delete", source, func() ([]ingest.PlanItem, error) {
+		return ingest.PlanRemove(source), nil
+	})
+	jobAccepted(c, gin.H{}, job, err)
+}
+
+// reindexDocument 处理 POST /v1/documents/:id/reindex，强制重新导入单个文件
+func (h *api) reindexDocument(c *gin.Context) {
+	source, _, ok := h.findDocument(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文档不存在"})
+		return
+	}
+	file := filepath.Join(h.knowledgeDir, filepath.FromSlash(source))
+	job, err := h.jobs.Submit("reindex", source, func() ([]ingest.PlanItem, error) {
+		return h.ingester.PlanPath(file, true)
+	})
+	jobAccepted(c, gin.H{}, job, err)
+}
+
+// jobAccepted 任务已排队时返回 202；队列已满时返回 503，已写入磁盘的改动留待下次同步处理
+func jobAccepted(c *gin.Context, body gin.H, job ingest.Job, err error) {
+	body["job"] = job
+	if err != nil {
+		body["error"] = err.Error()
+		c.JSON(http.StatusServiceUnavailable, body)
+		return
+	}
+	c.JSON(http.StatusAccepted, body)
+}
+
+// syncKnowledge 处理 POST /v1/ingest/sync，对整个知识库目录做一次增量同步
+func (h *api) syncKnowledge(c *gin.Context) {
+	job, err := h.jobs.Submit("sync", h.knowledgeDir, h.ingester.Plan)
+	jobAccepted(c, gin.H{}, job, err)
+}
+
+// listJobs 处理 GET /v1/ingest/jobs，返回最近的任务（不含文件明细）
+func (h *api) listJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": h.jobs.List(50)})
+}
+
+// getJob 处理 GET /v1/ingest/jobs/:id，返回任务状态与每个文件的处理阶段
+func (h *api) getJob(c *gin.Context) {
+	job, err := h.jobs.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
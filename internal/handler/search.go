@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iammm0/physics-llm/internal/retrieval"
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+const (
+	// DefaultSearchLimit /v1/search 默认每页的片段数
+	DefaultSearchLimit = 10
+	// SnippetWidth 高亮摘要的最大字符数
+	SnippetWidth = 160
+)
+
+// SearchRequest /v1/search 的参数：只检索，不调用 LLM。POST 时为 JSON 请求体，GET 时为查询参数
+type SearchRequest struct {
+	Query    string        `json:"query" form:"q" binding:"required"`
+	Limit    int           `json:"limit" form:"limit" binding:"omitempty,min=1,max=50"`
+	Offset   int           `json:"offset" form:"offset" binding:"omitempty,min=0,max=1000"`
+	MinScore float32       `json:"min_score" form:"min_score" binding:"omitempty,min=0,max=1"` // 向量相似度阈值
+	Rerank   string        `json:"rerank" form:"rerank" binding:"omitempty,oneof=none mmr cross-encoder llm"`
+	Filter   *store.Filter `json:"filter" form:"-"`
+}
+
+// SearchResult 一条检索结果
+type SearchResult struct {
+	Rank     int                    `json:"rank"` // 在全部结果中的名次，从 1 开始，跨页连续
+	ID       string                 `json:"id"`
+	Source   string                 `json:"source"`
+	Chunk    int                    `json:"chunk"`
+	Page     int                    `json:"page,omitempty"`
+	Slide    int                    `json:"slide,omitempty"`
+	Section  string                 `json:"section,omitempty"`
+	Label    string                 `json:"label"`
+	Score    float32                `json:"score"`
+	Snippet  string                 `json:"snippet"` // 截取的相关片段，命中词用 <em></em> 标出，其余内容已做 HTML 转义
+	Text     string                 `json:"text"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"` // course、experiment、language、file_type、tags
+}
+
+type SearchResponse struct {
+	Query      string         `json:"query"`
+	Offset     int            `json:"offset"`
+	Limit      int            `json:"limit"`
+	Results    []SearchResult `json:"results"`
+	HasMore    bool           `json:"has_more"`
+	NextOffset int            `json:"next_offset,omitempty"` // 下一页的 offset，has_more 为 false 时省略
+}
+
+// search 处理 POST /v1/search
+func (h *api) search(c *gin.Context) {
+	var req SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.doSearch(c, req)
+}
+
+/*
+searchGet 处理 GET /v1/search?q=…&limit=&offset=&min_score=&rerank=
+
+过滤条件可以用 filter 参数传 JSON（同 POST），也可以直接用字段名作参数，
+如 ?course=microwave&file_type=pdf&file_type=docx，重复的参数表示命中其一即可，字段之间为 must。
+*/
+func (h *api) searchGet(c *gin.Context) {
+	var req SearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if raw := c.Query("filter"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &req.Filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filter 不是合法的 JSON: " + err.Error()})
+			return
+		}
+	}
+	for key := range store.FilterKeys {
+		vals := c.QueryArray(key)
+		if len(vals) == 0 {
+			continue
+		}
+		if req.Filter == nil {
+			req.Filter = &store.Filter{}
+		}
+		if req.Filter.Must == nil {
+			req.Filter.Must = store.Conditions{}
+		}
+		req.Filter.Must[key] = vals
+	}
+	h.doSearch(c, req)
+}
+
+func (h *api) doSearch(c *gin.Context, req SearchRequest) {
+	if err := req.Filter.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = DefaultSearchLimit
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), RetrieveTimeout)
+	defer cancel()
+	// 多取一条用来判断是否还有下一页
+	hits, err := h.retriever.Retrieve(ctx, req.Query, retrieval.Options{
+		TopK:     req.Limit + 1,
+		Offset:   req.Offset,
+		Rerank:   req.Rerank,
+		Filter:   req.Filter,
+		MinScore: req.MinScore,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := SearchResponse{Query: req.Query, Offset: req.Offset, Limit: req.Limit}
+	if len(hits) > req.Limit {
+		hits = hits[:req.Limit]
+		resp.HasMore, resp.NextOffset = true, req.Offset+req.Limit
+	}
+	resp.Results = searchResults(hits, req.Query, req.Offset)
+	c.JSON(http.StatusOK, resp)
+}
+
+// searchResults 把检索结果转为响应格式，名次从 offset+1 开始，元数据字段单独归入 Metadata
+func searchResults(hits []store.Hit, query string, offset int) []SearchResult {
+	_, sources := numberHits(hits)
+	results := make([]SearchResult, len(hits))
+	for i, s := range sources {
+		results[i] = SearchResult{
+			Rank:    offset + i + 1,
+			ID:      s.ID,
+			Source:  s.Source,
+			Chunk:   s.Chunk,
+			Page:    s.Page,
+			Slide:   s.Slide,
+			Section: s.Section,
+			Label:   s.Label,
+			Score:   s.Score,
+			Snippet: retrieval.Highlight(s.Text, query, SnippetWidth),
+			Text:    s.Text,
+		}
+		for key := range store.FilterKeys {
+			if v, ok := hits[i].Payload[key]; ok && key != "source" {
+				if results[i].Metadata == nil {
+					results[i].Metadata = map[string]interface{}{}
+				}
+				results[i].Metadata[key] = v
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// Source 回答所依据的文档片段，Ref 与回答中的 [n] 引用编号一一对应
+type Source struct {
+	Ref     int     `json:"ref"`
+	ID      string  `json:"id"`
+	Source  string  `json:"source"`
+	Chunk   int     `json:"chunk"`
+	Page    int     `json:"page,omitempty"`
+	Slide   int     `json:"slide,omitempty"`
+	Section string  `json:"section,omitempty"` // 标题路径，如 "3 实验原理 > 3.2 巨磁阻效应"
+	Label   string  `json:"label"`             // 可直接展示的来源描述，与 prompt 中的一致
+	Score   float32 `json:"score"`
+	Text    string  `json:"text"`
+	Cited   bool    `json:"cited"` // 回答中是否出现了 [Ref]
+
+	heading string // 最近一级标题，用于生成简短的来源描述
+}
+
+// citationRe 匹配 [1]、[1,2]、[1，3] 之类的引用标记
+var citationRe = regexp.MustCompile(`\[(\d+(?:\s*[,，、]\s*\d+)*)\]`)
+
+// numberHits 给检索结果编号，返回写进 prompt 的上下文以及对应的 Source 列表
+func numberHits(hits []store.Hit) (string, []Source) {
+	sources := make([]Source, len(hits))
+	parts := make([]string, len(hits))
+	for i, hit := range hits {
+		sources[i] = Source{
+			Ref:     i + 1,
+			ID:      hit.ID,
+			Source:  hit.Source,
+			Chunk:   hit.Index,
+			Page:    hit.Page,
+			Slide:   hit.Slide,
+			Section: hit.Section,
+			heading: lastHeading(hit.Headings),
+			Score:   hit.Score,
+			Text:    hit.Text,
+		}
+		sources[i].Label = sources[i].label()
+		parts[i] = fmt.Sprintf("[%d] 来源：%s\n%s", i+1, sources[i].Label, hit.Text)
+	}
+	return strings.Join(parts, ContextSeparator), sources
+}
+
+// label 生成形如 "GMR.pdf §3.2 巨磁阻效应，第 14 页" 的来源描述
+func (s Source) label() string {
+	parts := []string{s.Source}
+	if s.heading != "" {
+		parts[0] += " §" + s.heading
+	}
+	switch {
+	case s.Page > 0:
+		parts = append(parts, fmt.Sprintf("第 %d 页", s.Page))
+	case s.Slide > 0:
+		parts = append(parts, fmt.Sprintf("第 %d 张幻灯片", s.Slide))
+	}
+	return strings.Join(parts, "，")
+}
+
+func lastHeading(headings []string) string {
+	if len(headings) == 0 {
+		return ""
+	}
+	return headings[len(headings)-1]
+}
+
+// markCited 解析回答中的 [n] 引用，把对应的来源标记为已引用；越界编号直接忽略
+func markCited(answer string, sources []Source) []Source {
+	for _, m := range citationRe.FindAllStringSubmatch(answer, -1) {
+		for _, f := range strings.FieldsFunc(m[1], func(r rune) bool {
+			return r == ',' || r == '，' || r == '、' || r == ' '
+		}) {
+			n, err := strconv.Atoi(f)
+			if err == nil && n >= 1 && n <= len(sources) {
+				sources[n-1].Cited = true
+			}
+		}
+	}
+	return sources
+}
@@ -0,0 +1,33 @@
+package handler
+
+import "testing"
+
+func TestMarkCited(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   []bool
+	}{
+		{"单个引用", "时间常数决定带宽 [2]。", []bool{false, true, false}},
+		{"英文逗号", "见 [1,3]", []bool{true, false, true}},
+		{"中文逗号", "见 [1，2]", []bool{true, true, false}},
+		{"顿号", "见 [1、3]", []bool{true, false, true}},
+		{"带空格", "见 [2 , 3]", []bool{false, true, true}},
+		{"越界编号忽略", "见 [0] [4] [3]", []bool{false, false, true}},
+		{"不是引用", "区间 [a, b] 与数组 x[i]", []bool{false, false, false}},
+		{"没有引用", "无法回答。", []bool{false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := make([]Source, len(tt.want))
+			for i := range sources {
+				sources[i].Ref = i + 1
+			}
+			for i, s := range markCited(tt.answer, sources) {
+				if s.Cited != tt.want[i] {
+					t.Errorf("[%d].Cited = %v, want %v", s.Ref, s.Cited, tt.want[i])
+				}
+			}
+		})
+	}
+}
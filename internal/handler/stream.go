@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iammm0/physics-llm/internal/llm"
+)
+
+/*
+SSE 事件约定（Content-Type: text/event-stream）：
+
+	event: reasoning data: {"content": "增量推理过程"}
+	event: token   data: {"content": "增量文本"}
+	event: done    data: {"sources": [{"ref": 1, "source": "...", "cited": true, ...}], "grounded": true, "conversation_id": "...", "debug": {...}}
+	event: error   data: {"error": "错误信息"}
+
+检索阶段出错时尚未开始推流，直接返回普通 JSON 错误。
+没有相关资料时 grounded 为 false，免责声明或拒答文案同样以 token 事件推送。
+推理模型（如 deepseek-r1）的思考过程以 reasoning 事件单独推送，不会出现在 token 中。
+*/
+
+// chatStream 处理 POST /v1/chat/stream，把模型的流式输出转为 SSE 推给前端
+func (h *api) chatStream(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := h.history(req.ConversationID)
+	if err != nil {
+		c.JSON(historyStatus(err), gin.H{"error": "读取会话失败: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), RetrieveTimeout)
+	g, err := h.retrieve(ctx, req.Query, history, req.options(), req.Expand)
+	cancel()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// 生成阶段不设超时，客户端断开时 Request.Context 会被取消，进而中断模型请求
+	genCtx := c.Request.Context()
+	reply, err := h.generate(genCtx, history, g, func(delta llm.Delta) error {
+		if delta.Reasoning != "" {
+			c.SSEvent("reasoning", gin.H{"content": delta.Reasoning})
+		}
+		if delta.Content != "" {
+			c.SSEvent("token", gin.H{"content": delta.Content})
+		}
+		c.Writer.Flush()
+		return genCtx.Err()
+	})
+	if err != nil {
+		if genCtx.Err() != nil {
+			return // 客户端已断开，无需再写
+		}
+		c.SSEvent("error", gin.H{"error": "调用模型失败: " + err.Error()})
+		c.Writer.Flush()
+		return
+	}
+
+	if err := h.record(req.ConversationID, req.Query, g.query, reply); err != nil {
+		c.SSEvent("error", gin.H{"error": "保存会话失败: " + err.Error()})
+		c.Writer.Flush()
+		return
+	}
+
+	done := gin.H{"sources": markCited(reply.Content, g.sources), "grounded": g.grounded, "conversation_id": req.ConversationID}
+	if g.debug != nil {
+		done["debug"] = g.debug
+	}
+	c.SSEvent("done", done)
+	c.Writer.Flush()
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/iammm0/physics-llm/internal/llm"
+)
+
+/*
+WebSocket 帧约定（均为 JSON 文本帧）：
+
+客户端 → 服务端
+
+	{"type": "chat", "query": "问题", "conversation_id": "可选"}
+	                                     提问；同一连接内可连续追问。
+	                                     带 conversation_id 时使用服务端会话历史，否则仅在本连接内记忆；
+	                                     可选的 rerank、filter、expand 字段与 /v1/chat 相同
+	{"type": "stop"}                     取消正在生成的回答
+
+服务端 → 客户端
+
+	{"type": "reasoning", "content": "增量推理过程"}
+	                                     推理模型的思考过程，与回答分开推送
+	{"type": "token",   "content": "增量文本"}
+	{"type": "done",    "sources": [{"ref": 1, "source": "...", "cited": true, ...}], "grounded": true, "debug": {...}}
+	{"type": "stopped"}                  已按 stop 取消
+	{"type": "error",   "error": "错误信息"}
+
+同一连接同时只允许一个回答在生成；stop 或断开连接都会取消模型请求。
+*/
+
+const (
+	// wsMaxHistoryTurns 单个连接内保留的历史轮数（一问一答为一轮）
+	wsMaxHistoryTurns = 5
+
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsWriteWait  = 10 * time.Second
+)
+
+type wsInbound struct {
+	Type string `json:"type"`
+	ChatRequest
+}
+
+type wsOutbound struct {
+	Type           string   `json:"type"`
+	Content        string   `json:"content,omitempty"`
+	Sources        []Source `json:"sources,omitempty"`
+	Grounded       *bool    `json:"grounded,omitempty"` // 仅 done 帧携带
+	ConversationID string   `json:"conversation_id,omitempty"`
+	Debug          *Debug   `json:"debug,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// wsConn 包装一条连接：写操作加锁串行化，并记录当前生成任务的取消函数
+type wsConn struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	history []llm.Message
+}
+
+func (w *wsConn) send(msg wsOutbound) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	_ = w.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return w.conn.WriteJSON(msg)
+}
+
+func (w *wsConn) ping() error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	return w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+}
+
+// newUpgrader 只接受配置中允许的前端来源
+func newUpgrader(origins []string) websocket.Upgrader {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[o] = true
+	}
+	return websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || allowed["*"] || allowed[origin]
+		},
+	}
+}
+
+// chatWS 处理 GET /v1/ws
+func (h *api) chatWS(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // Upgrade 已写回 HTTP 错误
+	}
+	defer conn.Close()
+
+	// 连接级上下文：断开时取消所有进行中的生成
+	connCtx, connCancel := context.WithCancel(c.Request.Context())
+	defer connCancel()
+
+	w := &wsConn{conn: conn}
+
+	conn.SetReadLimit(64 * 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	go func() {
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-connCtx.Done():
+				return
+			case <-ticker.C:
+				if err := w.ping(); err != nil {
+					connCancel()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var msg wsInbound
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case "chat":
+			if err := msg.validate(); err != nil {
+				_ = w.send(wsOutbound{Type: "error", Error: err.Error()})
+				continue
+			}
+			w.mu.Lock()
+			if w.cancel != nil {
+				w.mu.Unlock()
+				_ = w.send(wsOutbound{Type: "error", Error: "上一个回答仍在生成，请先发送 stop"})
+				continue
+			}
+			genCtx, cancel := context.WithCancel(connCtx)
+			w.cancel = cancel
+			history := append([]llm.Message(nil), w.history...)
+			w.mu.Unlock()
+
+			go h.wsAnswer(genCtx, w, msg, history)
+
+		case "stop":
+			w.mu.Lock()
+			if w.cancel != nil {
+				w.cancel()
+			}
+			w.mu.Unlock()
+
+		default:
+			_ = w.send(wsOutbound{Type: "error", Error: "未知消息类型: " + msg.Type})
+		}
+	}
+}
+
+// wsAnswer 检索 + 流式生成一次回答，结束后把本轮写入会话或连接历史
+func (h *api) wsAnswer(ctx context.Context, w *wsConn, msg wsInbound, history []llm.Message) {
+	defer func() {
+		w.mu.Lock()
+		w.cancel()
+		w.cancel = nil
+		w.mu.Unlock()
+	}()
+
+	query := msg.Query
+	if msg.ConversationID != "" {
+		var err error
+		if history, err = h.history(msg.ConversationID); err != nil {
+			h.wsFail(ctx, w, fmt.Errorf("读取会话失败: %w", err))
+			return
+		}
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, RetrieveTimeout)
+	g, err := h.retrieve(rctx, query, history, msg.options(), msg.Expand)
+	cancel()
+	if err != nil {
+		h.wsFail(ctx, w, err)
+		return
+	}
+
+	reply, err := h.generate(ctx, history, g, func(delta llm.Delta) error {
+		if delta.Reasoning != "" {
+			if err := w.send(wsOutbound{Type: "reasoning", Content: delta.Reasoning}); err != nil {
+				return err
+			}
+		}
+		if delta.Content != "" {
+			return w.send(wsOutbound{Type: "token", Content: delta.Content})
+		}
+		return nil
+	})
+	if err != nil {
+		h.wsFail(ctx, w, err)
+		return
+	}
+
+	if msg.ConversationID != "" {
+		if err := h.record(msg.ConversationID, query, g.query, reply); err != nil {
+			h.wsFail(ctx, w, fmt.Errorf("保存会话失败: %w", err))
+			return
+		}
+		_ = w.send(wsOutbound{Type: "done", Sources: markCited(reply.Content, g.sources), Grounded: &g.grounded,
+			ConversationID: msg.ConversationID, Debug: g.debug})
+		return
+	}
+
+	// 历史里只记原始问题和去掉推理过程的回答，避免把检索片段、思考过程反复塞进上下文
+	w.mu.Lock()
+	w.history = append(w.history,
+		llm.Message{Role: "user", Content: query},
+		llm.Message{Role: "assistant", Content: reply.Content},
+	)
+	if n := len(w.history) - wsMaxHistoryTurns*2; n > 0 {
+		w.history = w.history[n:]
+	}
+	w.mu.Unlock()
+
+	_ = w.send(wsOutbound{Type: "done", Sources: markCited(reply.Content, g.sources), Grounded: &g.grounded, Debug: g.debug})
+}
+
+// wsFail 区分用户主动取消与真正的错误
+func (h *api) wsFail(ctx context.Context, w *wsConn, err error) {
+	if ctx.Err() != nil {
+		_ = w.send(wsOutbound{Type: "stopped"})
+		return
+	}
+	_ = w.send(wsOutbound{Type: "error", Error: err.Error()})
+}
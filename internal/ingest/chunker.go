@@ -0,0 +1,320 @@
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/llm"
+	"github.com/iammm0/physics-llm/internal/llm/provider"
+)
+
+// Chunker 把提取出的纯文本切成适合 Embedding 的片段
+type Chunker interface {
+	Chunk(text string) []string
+}
+
+// 可选的切片策略（CHUNK_STRATEGY）
+const (
+	ChunkRune      = "rune"      // 按字符定长窗口 + 重叠
+	ChunkSentence  = "sentence"  // 按句子（含中文 。！？）聚合到上限
+	ChunkParagraph = "paragraph" // 按段落 / 标题聚合，超长段落再按句子切
+	ChunkToken     = "token"     // 同 sentence，但按 Embedding 模型的 token 数计量
+	ChunkSemantic  = "semantic"  // 公式、步骤列表、表格、图注作为不可分割单元，再聚合到上限
+)
+
+// NewChunker 按配置创建切片器；CHUNK_SIZE / CHUNK_OVERLAP 在 token 模式下是 token 数，其余模式是字符数
+func NewChunker(cfg *config.Config) (Chunker, error) {
+	size, overlap := cfg.ChunkSize, cfg.ChunkOverlap
+	if size <= 0 {
+		return nil, fmt.Errorf("CHUNK_SIZE 必须大于 0")
+	}
+	if overlap < 0 || overlap >= size {
+		return nil, fmt.Errorf("CHUNK_OVERLAP 必须在 [0, CHUNK_SIZE) 之间")
+	}
+
+	runes := func(s string) int { return utf8.RuneCountInString(s) }
+	switch cfg.ChunkStrategy {
+	case ChunkRune, "":
+		return runeChunker{size: size, overlap: overlap}, nil
+	case ChunkSentence:
+		return sentenceChunker{size: size, overlap: overlap, measure: runes}, nil
+	case ChunkParagraph:
+		return paragraphChunker{sentenceChunker{size: size, overlap: overlap, measure: runes}}, nil
+	case ChunkSemantic:
+		return semanticChunker{sentenceChunker{size: size, overlap: overlap, measure: runes}}, nil
+	case ChunkToken:
+		limit := modelTokenLimit(provider.EmbedModel(cfg))
+		if size > limit {
+			size = limit // 超过模型上下文的部分会被 Embedding 截断
+		}
+		if overlap >= size {
+			overlap = size / 10
+		}
+		return sentenceChunker{size: size, overlap: overlap, measure: llm.EstimateTokens}, nil
+	}
+	return nil, fmt.Errorf("未知的 CHUNK_STRATEGY: %s", cfg.ChunkStrategy)
+}
+
+// segmentVersion 分段提取与 payload 格式的版本，提取逻辑变化时递增，已导入的文件会全部重导
+const segmentVersion = 2
+
+// chunkerFingerprint 切片参数的指纹，记录在清单里；参数变化后文件会被视为需要重导
+func chunkerFingerprint(cfg *config.Config) string {
+	strategy := cfg.ChunkStrategy
+	if strategy == "" {
+		strategy = ChunkRune
+	}
+	return fmt.Sprintf("%s/%d/%d/v%d", strategy, cfg.ChunkSize, cfg.ChunkOverlap, segmentVersion)
+}
+
+// runeChunker 按 rune 定长切分，不会切断多字节 UTF-8 字符
+type runeChunker struct{ size, overlap int }
+
+func (c runeChunker) Chunk(text string) []string {
+	r := []rune(text)
+	var chunks []string
+	for start := 0; start < len(r); start += c.size - c.overlap {
+		end := start + c.size
+		if end > len(r) {
+			end = len(r)
+		}
+		if chunk := strings.TrimSpace(string(r[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(r) {
+			break
+		}
+	}
+	return chunks
+}
+
+// sentenceChunker 先切句，再把相邻句子聚合到 size 以内；相邻片段之间保留不超过 overlap 的尾部句子
+type sentenceChunker struct {
+	size, overlap int
+	measure       func(string) int
+}
+
+func (c sentenceChunker) Chunk(text string) []string {
+	return c.pack(splitSentences(text), "", c.hardSplit)
+}
+
+// pack 把 units 依次聚合成不超过 size 的片段，sep 为单元之间的连接符。
+// 单个单元超长时单独交给 oversize 处理（通常是 hardSplit 硬切）。
+func (c sentenceChunker) pack(units []string, sep string, oversize func(string) []string) []string {
+	var (
+		chunks []string
+		cur    []string // 当前片段的单元，开头可能是上一片段的重叠尾巴
+		curLen int
+		fresh  bool // cur 中是否有尚未输出过的单元
+	)
+	emit := func() {
+		if chunk := strings.TrimSpace(strings.Join(cur, sep)); fresh && chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		fresh = false
+	}
+	// tail 从尾部回收不超过 overlap 的单元，作为下一个片段的开头
+	tail := func() {
+		var keep []string
+		kept := 0
+		for i := len(cur) - 1; i >= 0; i-- {
+			n := c.measure(cur[i])
+			if kept+n > c.overlap {
+				break
+			}
+			keep = append([]string{cur[i]}, keep...)
+			kept += n
+		}
+		cur, curLen = keep, kept
+	}
+
+	for _, u := range units {
+		n := c.measure(u)
+		if n > c.size {
+			emit()
+			cur, curLen = nil, 0
+			chunks = append(chunks, oversize(u)...)
+			continue
+		}
+		if curLen+n > c.size {
+			emit()
+			tail()
+			if curLen+n > c.size {
+				cur, curLen = nil, 0 // 重叠部分加上当前单元仍放不下时放弃重叠
+			}
+		}
+		cur = append(cur, u)
+		curLen += n
+		fresh = true
+	}
+	emit()
+	return chunks
+}
+
+// hardSplit 按计量函数把超长单元切成不超过 size 的若干段
+func (c sentenceChunker) hardSplit(u string) []string {
+	var out []string
+	r := []rune(u)
+	for len(r) > 0 {
+		// 二分找到不超过 size 的最长前缀
+		lo, hi := 1, len(r)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if c.measure(string(r[:mid])) <= c.size {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		if s := strings.TrimSpace(string(r[:lo])); s != "" {
+			out = append(out, s)
+		}
+		r = r[lo:]
+	}
+	return out
+}
+
+// sentenceEnd 中英文句末标点
+func sentenceEnd(r rune) bool {
+	switch r {
+	case '。', '！', '？', '；', '!', '?', ';', '…':
+		return true
+	}
+	return false
+}
+
+// closing 句末标点后可能紧跟的右引号 / 右括号，应归入前一句
+func closing(r rune) bool {
+	switch r {
+	case '”', '’', '"', '\'', '）', ')', '】', '」', '』':
+		return true
+	}
+	return false
+}
+
+// splitSentences 按句末标点、英文句点 + 空白、换行切句；保留标点与原有空白
+func splitSentences(text string) []string {
+	var out []string
+	r := []rune(text)
+	start := 0
+	emit := func(end int) {
+		s := string(r[start:end])
+		switch {
+		case strings.TrimSpace(s) != "":
+			out = append(out, s)
+		case len(out) > 0:
+			out[len(out)-1] += s // 纯空白（如句末标点后的换行）并入前一句
+		}
+		start = end
+	}
+	for i := 0; i < len(r); i++ {
+		switch {
+		case r[i] == '\n':
+			emit(i + 1)
+		case sentenceEnd(r[i]):
+			j := i + 1
+			for j < len(r) && (sentenceEnd(r[j]) || closing(r[j])) {
+				j++
+			}
+			emit(j)
+			i = j - 1
+		case r[i] == '.' && i+1 < len(r) && unicode.IsSpace(r[i+1]) && !isAbbrev(r, i):
+			emit(i + 1)
+		}
+	}
+	emit(len(r))
+	return out
+}
+
+// isAbbrev 排除 "Fig. 3"、"e.g. "、"1. " 之类不是句末的英文句点
+func isAbbrev(r []rune, dot int) bool {
+	j := dot
+	for j > 0 && unicode.IsLetter(r[j-1]) && r[j-1] < unicode.MaxASCII {
+		j--
+	}
+	word := strings.ToLower(string(r[j:dot]))
+	switch word {
+	case "fig", "eq", "ref", "e.g", "i.e", "vs", "etc", "no", "dr", "mr", "prof":
+		return true
+	}
+	// 单字母缩写或编号（"A. "、"1. "）
+	return dot > 0 && (dot-j == 1 || unicode.IsDigit(r[dot-1]))
+}
+
+// paragraphChunker 以段落为单位聚合，标题总是开启新片段并与后续段落放在一起
+type paragraphChunker struct{ sentenceChunker }
+
+// headingRe 识别 Markdown 标题、"第X章/节"、"1.2 标题"、"一、标题" 等标题行
+var headingRe = regexp.MustCompile(`^(#{1,6}\s+\S|第[一二三四五六七八九十百\d]+[章节部分篇]|\d+(\.\d+)*\s+\S|[一二三四五六七八九十]+[、.．]\S)`)
+
+func (c paragraphChunker) Chunk(text string) []string {
+	var chunks []string
+	var section []string
+	flush := func() {
+		chunks = append(chunks, c.packParagraphs(section)...)
+		section = nil
+	}
+	for _, p := range splitParagraphs(text) {
+		if isHeading(p) {
+			flush()
+		}
+		section = append(section, p)
+	}
+	flush()
+	return chunks
+}
+
+// packParagraphs 聚合同一节内的段落；超长段落拆成句子后再聚合
+func (c paragraphChunker) packParagraphs(paras []string) []string {
+	var units []string
+	for _, p := range paras {
+		if c.measure(p) > c.size {
+			units = append(units, splitSentences(p)...)
+			continue
+		}
+		units = append(units, p+"\n\n")
+	}
+	return c.pack(units, "", c.hardSplit)
+}
+
+// blankLineRe 段落之间的空行
+var blankLineRe = regexp.MustCompile(`\n\s*\n`)
+
+// splitParagraphs 按空行切段
+func splitParagraphs(text string) []string {
+	var out []string
+	for _, p := range blankLineRe.Split(text, -1) {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func isHeading(p string) bool {
+	line, _, _ := strings.Cut(p, "\n")
+	return utf8.RuneCountInString(line) <= 60 && headingRe.MatchString(strings.TrimSpace(line))
+}
+
+// modelTokenLimit Embedding 模型的最大输入 token 数，未知模型按 512 处理；
+// OpenAI 兼容服务的模型名常带组织前缀（如 BAAI/bge-m3），只看最后一段
+func modelTokenLimit(model string) int {
+	name := strings.ToLower(model[strings.LastIndex(model, "/")+1:])
+	for prefix, limit := range map[string]int{
+		"mxbai-embed-large":      512,
+		"nomic-embed-text":       8192,
+		"bge-m3":                 8192,
+		"bge-large":              512,
+		"all-minilm":             256,
+		"snowflake-arctic-embed": 512,
+	} {
+		if strings.HasPrefix(name, prefix) {
+			return limit
+		}
+	}
+	return 512
+}
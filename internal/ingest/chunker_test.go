@@ -0,0 +1,178 @@
+package ingest
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/iammm0/physics-llm/internal/config"
+)
+
+func TestNewChunker(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{"默认策略", config.Config{ChunkSize: 500, ChunkOverlap: 50}, false},
+		{"CHUNK_SIZE 为 0", config.Config{ChunkSize: 0}, true},
+		{"重叠不小于大小", config.Config{ChunkSize: 100, ChunkOverlap: 100}, true},
+		{"负重叠", config.Config{ChunkSize: 100, ChunkOverlap: -1}, true},
+		{"未知策略", config.Config{ChunkStrategy: "words", ChunkSize: 100}, true},
+		{"semantic", config.Config{ChunkStrategy: ChunkSemantic, ChunkSize: 100, ChunkOverlap: 10}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewChunker(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// token 策略的大小不超过所用 Embedding 模型的输入上限，模型按 EMBED_PROVIDER 选择
+func TestNewChunkerTokenLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		size int
+	}{
+		{"ollama 小模型", config.Config{OllamaEmbedModel: "all-minilm", OpenAIEmbedModel: "bge-m3"}, 256},
+		{"openai 带组织前缀", config.Config{EmbedProvider: "openai", OllamaEmbedModel: "all-minilm", OpenAIEmbedModel: "BAAI/bge-m3"}, 1000},
+		{"未知模型", config.Config{OllamaEmbedModel: "custom-embed"}, 512},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.ChunkStrategy, tt.cfg.ChunkSize, tt.cfg.ChunkOverlap = ChunkToken, 1000, 900
+			c, err := NewChunker(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sc := c.(sentenceChunker)
+			if sc.size != tt.size || sc.overlap >= sc.size {
+				t.Errorf("size = %d overlap = %d, want size %d", sc.size, sc.overlap, tt.size)
+			}
+		})
+	}
+}
+
+func TestRuneChunkerOverlap(t *testing.T) {
+	got := runeChunker{size: 10, overlap: 3}.Chunk("abcdefghijklmnopqrstuvwxyz")
+	want := []string{"abcdefghij", "hijklmnopq", "opqrstuvwx", "vwxyz"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// 多字节字符按 rune 计数，不会被切断
+	for _, c := range (runeChunker{size: 4, overlap: 1}).Chunk("动能定理与动量守恒定律") {
+		if !utf8.ValidString(c) || utf8.RuneCountInString(c) > 4 {
+			t.Errorf("非法片段 %q", c)
+		}
+	}
+}
+
+func TestSentenceChunker(t *testing.T) {
+	c := sentenceChunker{size: 21, overlap: 10, measure: func(s string) int { return utf8.RuneCountInString(s) }}
+	text := "光在真空中沿直线传播。反射角等于入射角。折射遵循斯涅尔定律。全反射需要光密到光疏。"
+	chunks := c.Chunk(text)
+	if len(chunks) < 2 {
+		t.Fatalf("应切成多个片段，got %q", chunks)
+	}
+	for i, ch := range chunks {
+		if n := utf8.RuneCountInString(ch); n > c.size {
+			t.Errorf("片段 %d 长 %d，超过上限 %d: %q", i, n, c.size, ch)
+		}
+		if i > 0 {
+			// 相邻片段重叠一整句：上一片段的最后一句是下一片段的开头
+			sents := splitSentences(chunks[i-1])
+			if last := sents[len(sents)-1]; !strings.HasPrefix(ch, last) {
+				t.Errorf("片段 %d 没有以上一片段的末句 %q 开头: %q", i, last, ch)
+			}
+		}
+	}
+
+	// 单句超长时硬切，仍不超过上限
+	for _, ch := range c.Chunk(strings.Repeat("长", 45)) {
+		if n := utf8.RuneCountInString(ch); n > c.size {
+			t.Errorf("硬切片段长 %d，超过上限 %d", n, c.size)
+		}
+	}
+}
+
+func TestStepRe(t *testing.T) {
+	tests := []struct {
+		line string
+		step bool
+	}{
+		{"1. 连接电路", true},
+		{"2) 闭合开关", true},
+		{"3、记录读数", true},
+		{"3、 记录读数", true},
+		{"4）调节电阻", true},
+		{"(5) 重复测量", true},
+		{"① 预热仪器", true},
+		{"步骤2 读数", true},
+		{"第三步 计算", true},
+		{"Step 1: calibrate", true},
+		{"2.5 kg 的砝码挂在弹簧下端", false},
+		{"9.8 m/s² 为重力加速度", false},
+		{"1.2 实验原理", false},
+		{"10)", false},
+		{"电阻 R = U / I", false},
+	}
+	for _, tt := range tests {
+		if got := stepRe.MatchString(tt.line); got != tt.step {
+			t.Errorf("stepRe.MatchString(%q) = %v, want %v", tt.line, got, tt.step)
+		}
+	}
+}
+
+func TestSemanticChunkerGrouping(t *testing.T) {
+	c := semanticChunker{sentenceChunker{size: 60, overlap: 0, measure: func(s string) int { return utf8.RuneCountInString(s) }}}
+	text := strings.Join([]string{
+		"实验步骤如下。",
+		"",
+		"1. 按图连接电路，检查电源极性。",
+		"2. 闭合开关，调节滑动变阻器。",
+		"3. 记录电压表与电流表读数。",
+		"",
+		"$$",
+		"R = \\frac{U}{I}",
+		"$$",
+		"",
+		"2.5 kg 的砝码挂在弹簧下端。9.8 m/s² 为重力加速度。",
+	}, "\n")
+
+	blocks := parseBlocks(text)
+	var kinds []blockKind
+	for _, b := range blocks {
+		kinds = append(kinds, b.kind)
+	}
+	if want := []blockKind{blockText, blockSteps, blockMath, blockText}; !slices.Equal(kinds, want) {
+		t.Fatalf("块类型 = %v, want %v", kinds, want)
+	}
+
+	chunks := c.Chunk(text)
+	find := func(sub string) string {
+		for _, ch := range chunks {
+			if strings.Contains(ch, sub) {
+				return ch
+			}
+		}
+		t.Fatalf("没有片段包含 %q: %q", sub, chunks)
+		return ""
+	}
+	// 步骤列表与公式各自完整地出现在同一个片段里
+	if steps := find("1. 按图"); !strings.Contains(steps, "3. 记录") {
+		t.Errorf("步骤列表被拆开: %q", steps)
+	}
+	if formula := find("$$\nR ="); !strings.Contains(formula, "{I}\n$$") {
+		t.Errorf("公式块被拆开: %q", formula)
+	}
+	// 以小数开头的行是正文，不会并进步骤列表
+	if weights := find("2.5 kg"); strings.Contains(weights, "1. 按图") {
+		t.Errorf("小数开头的行被当成步骤: %q", weights)
+	}
+}
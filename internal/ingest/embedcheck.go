@@ -0,0 +1,131 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/llm/provider"
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// Embedding 模型与已有集合不一致时的处理方式（EMBED_MISMATCH）
+const (
+	MismatchFail    = "fail"    // 报错退出，由人决定删集合重导还是换回原模型
+	MismatchMigrate = "migrate" // 改用以模型名命名的新集合，清单中的文件因模型变化全部重导
+)
+
+// probeText 启动时用来探测 Embedding 维度的文本
+const probeText = "维度探测 dimension probe"
+
+// collectionUnsafe 模型名中不适合出现在集合名里的字符，如 "bge-m3:latest" 中的冒号
+var collectionUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+/*
+CheckEmbedding 启动时核对 Embedding 模型与向量库，并确保集合存在
+
+先为一段探测文本生成向量，得到模型的实际维度，与 EMBED_DIM 不同直接报错；
+再与已有集合比较：维度不同，或集合中的点记录的 embed_model 与当前模型不同，都视为不一致，
+按 EMBED_MISMATCH 报错退出，或把 cfg.QdrantCol 改为以模型名命名的新集合。
+之后创建的 Ingester 与检索都读 cfg，因此必须在 New / RegisterRoutes 之前调用。
+*/
+func CheckEmbedding(ctx context.Context, cfg *config.Config) error {
+	switch cfg.EmbedMismatch {
+	case MismatchFail, MismatchMigrate, "":
+	default:
+		return fmt.Errorf("未知的 EMBED_MISMATCH: %s", cfg.EmbedMismatch)
+	}
+
+	dim, err := probeEmbedding(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	model := provider.EmbedModel(cfg)
+
+	db, err := store.New(cfg)
+	if err != nil {
+		return err
+	}
+	reason, err := mismatch(ctx, db, dim, model)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		if cfg.EmbedMismatch != MismatchMigrate {
+			return fmt.Errorf("集合 %s %s；可执行 ingest rebuild 构建新版本并切换（Qdrant），"+
+				"或设置 EMBED_MISMATCH=migrate 改用新集合，或删除该集合后执行 ingest reindex", cfg.QdrantCol, reason)
+		}
+		target := migrateCollection(cfg.QdrantCol, model, dim)
+		log.Printf("集合 %s %s，改用集合 %s\n", cfg.QdrantCol, reason, target)
+		cfg.QdrantCol = target
+		if db, err = store.New(cfg); err != nil {
+			return err
+		}
+		if reason, err = mismatch(ctx, db, dim, model); err != nil {
+			return err
+		}
+		if reason != "" {
+			return fmt.Errorf("迁移目标集合 %s %s", target, reason)
+		}
+	}
+	return db.EnsureCollection(dim)
+}
+
+// probeEmbedding 为探测文本生成向量，返回模型的实际维度；与 EMBED_DIM 不同时报错
+func probeEmbedding(ctx context.Context, cfg *config.Config) (int, error) {
+	embedder, err := provider.NewEmbedder(cfg)
+	if err != nil {
+		return 0, err
+	}
+	model := provider.EmbedModel(cfg)
+	vec, err := embedder.Embeddings(ctx, probeText)
+	if err != nil {
+		return 0, fmt.Errorf("探测 Embedding 模型 %s 失败: %w", model, err)
+	}
+	dim := len(vec)
+	if dim == 0 {
+		return 0, fmt.Errorf("embedding 模型 %s 返回了空向量", model)
+	}
+	if dim != cfg.EmbedDim {
+		return 0, fmt.Errorf("EMBED_DIM=%d，但 Embedding 模型 %s 实际输出 %d 维向量，请修改 EMBED_DIM", cfg.EmbedDim, model, dim)
+	}
+	return dim, nil
+}
+
+// mismatch 比较已有集合与当前模型，一致（或集合还不存在）时返回空串，否则返回原因
+func mismatch(ctx context.Context, db store.VectorStore, dim int, model string) (string, error) {
+	actual, err := db.Dimension(ctx)
+	if err != nil {
+		return "", err
+	}
+	if actual == 0 {
+		return "", nil
+	}
+	if actual != dim {
+		return fmt.Sprintf("的向量维度为 %d，而模型 %s 输出 %d 维", actual, model, dim), nil
+	}
+
+	// 维度相同的两个模型语义空间也不同，再抽一个点看它是哪个模型生成的；旧版本导入的点没有该字段，无从判断
+	points, _, err := db.Scroll(ctx, store.ScrollParams{Limit: 1})
+	if err != nil {
+		return "", err
+	}
+	if len(points) > 0 {
+		if prev, _ := points[0].Payload["embed_model"].(string); prev != "" && prev != model {
+			return fmt.Sprintf("中的向量由模型 %s 生成，与当前的 %s 不一致", prev, model), nil
+		}
+	}
+	return "", nil
+}
+
+// migrateCollection 迁移目标集合名，如 physics + "bge-m3:latest" → physics_bge-m3_latest；模型名为空时用维度
+func migrateCollection(base, model string, dim int) string {
+	suffix := strings.Trim(collectionUnsafe.ReplaceAllString(model, "_"), "_")
+	if suffix == "" {
+		suffix = fmt.Sprintf("d%d", dim)
+	}
+	return base + "_" + suffix
+}
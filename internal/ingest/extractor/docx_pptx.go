@@ -6,98 +6,291 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
-	"path/filepath"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// docx 读取 word/document.xml 并抽取文本节点
+// docx 读取 word/document.xml，按标题样式分节
 type docx struct{}
 
-func (docx) Extract(p string) (string, error) {
-	zr, err := zip.OpenReader(p)
+func (d docx) Extract(p string) (string, error) {
+	segs, err := d.ExtractSegments(p)
 	if err != nil {
 		return "", err
 	}
+	return joinSegments(segs), nil
+}
+
+// ExtractSegments 以标题段落（Heading1… / 标题 1… / 大纲级别）切节，每节带上标题路径；
+// 节内正文段落样式一致时记录在 Style 里
+func (docx) ExtractSegments(p string) ([]Segment, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
 	defer zr.Close()
 
-	var raw []byte
-	for _, f := range zr.File {
-		if f.Name == "word/document.xml" {
-			rc, err := f.Open()
-			if err != nil {
-				return "", err
-			}
-			raw, err = io.ReadAll(rc)
-			rc.Close()
-			if err != nil {
-				return "", err
+	raw, err := readZipFile(&zr.Reader, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("docx: 找不到 word/document.xml")
+	}
+
+	var (
+		segs  []Segment
+		path  headingPath
+		lines []string
+		style string // 当前节正文的统一样式，混用多种样式时为空
+		mixed bool
+		body  int // 当前节已有的正文段落数
+	)
+	flush := func() {
+		if t := strings.TrimSpace(strings.Join(lines, "\n")); t != "" {
+			if mixed {
+				style = ""
 			}
-			break
+			segs = append(segs, Segment{Text: t, Headings: path, Style: style})
 		}
+		lines, style, mixed, body = nil, "", false, 0
 	}
-	if raw == nil {
-		return "", fmt.Errorf("docx: 找不到 word/document.xml")
+	for _, para := range docxParagraphs(raw) {
+		if strings.TrimSpace(para.text) == "" {
+			continue
+		}
+		if level := para.headingLevel(); level > 0 {
+			flush()
+			path = path.enter(level, strings.TrimSpace(para.text))
+			lines = append(lines, para.text)
+			continue
+		}
+		if body == 0 {
+			style = para.style
+		} else if para.style != style {
+			mixed = true
+		}
+		body++
+		lines = append(lines, para.text)
+	}
+	flush()
+	return segs, nil
+}
+
+// docxPara document.xml 中的一个 <w:p>
+type docxPara struct {
+	text         string
+	style        string // <w:pStyle w:val>
+	outlineLevel int    // <w:outlineLvl w:val> + 1，0 表示无
+}
+
+// docxHeadingStyleRe 英文 / 中文 Word 的标题样式 ID：Heading1、heading 2、标题 3，或中文版的纯数字 "1"
+var docxHeadingStyleRe = regexp.MustCompile(`(?i)^(?:heading\s*|标题\s*)?([1-9])$`)
+
+// headingLevel 标题级别，普通段落返回 0
+func (p docxPara) headingLevel() int {
+	if p.outlineLevel > 0 {
+		return p.outlineLevel
+	}
+	if strings.EqualFold(p.style, "Title") {
+		return 1
+	}
+	if m := docxHeadingStyleRe.FindStringSubmatch(p.style); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+// docxParagraphs 按顺序解析正文段落的文本与样式
+func docxParagraphs(raw []byte) []docxPara {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	var (
+		paras  []docxPara
+		cur    *docxPara
+		sb     strings.Builder
+		inText bool
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break // EOF 或损坏的 XML：返回已解析的段落
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				cur = &docxPara{}
+				sb.Reset()
+			case "pStyle":
+				if cur != nil {
+					cur.style = xmlAttr(t, "val")
+				}
+			case "outlineLvl":
+				if cur != nil {
+					if n, err := strconv.Atoi(xmlAttr(t, "val")); err == nil && n < 9 {
+						cur.outlineLevel = n + 1
+					}
+				}
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if cur != nil {
+					cur.text = sb.String()
+					paras = append(paras, *cur)
+					cur = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
 	}
-	return extractTextFromXML(raw, "w:t", "w:p"), nil
+	return paras
 }
 
-// pptx 读取 ppt/slides/slideN.xml 并抽取文本节点
+// pptx 读取 ppt/slides/slideN.xml，每张幻灯片一个分段
 type pptx struct{}
 
-func (pptx) Extract(p string) (string, error) {
-	zr, err := zip.OpenReader(p)
+func (pp pptx) Extract(p string) (string, error) {
+	segs, err := pp.ExtractSegments(p)
 	if err != nil {
 		return "", err
 	}
+	return joinSegments(segs), nil
+}
+
+// slideNameRe 幻灯片文件名，用于取序号
+var slideNameRe = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// ExtractSegments 按幻灯片序号（而非 zip 内顺序）提取，标题占位符的文字作为该页标题
+func (pptx) ExtractSegments(p string) ([]Segment, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
 	defer zr.Close()
 
-	var allText []string
+	type slide struct {
+		n int
+		f *zip.File
+	}
+	var slides []slide
 	for _, f := range zr.File {
-		// slide 文件位于 ppt/slides/slideX.xml
-		if filepath.Dir(f.Name) == "ppt/slides" && filepath.Ext(f.Name) == ".xml" {
-			rc, err := f.Open()
-			if err != nil {
-				return "", err
-			}
-			raw, err := io.ReadAll(rc)
-			rc.Close()
-			if err != nil {
-				return "", err
-			}
-			// pptx 文本节点是 <a:t>，段落结束用 <a:p>
-			txt := extractTextFromXML(raw, "a:t", "a:p")
-			allText = append(allText, txt)
+		if m := slideNameRe.FindStringSubmatch(f.Name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			slides = append(slides, slide{n, f})
+		}
+	}
+	sort.Slice(slides, func(i, j int) bool { return slides[i].n < slides[j].n })
+
+	var segs []Segment
+	for _, s := range slides {
+		raw, err := readZip(s.f)
+		if err != nil {
+			return nil, err
+		}
+		title, text := slideText(raw)
+		if strings.TrimSpace(text) == "" {
+			continue
 		}
+		seg := Segment{Text: text, Slide: s.n}
+		if title != "" {
+			seg.Headings = []string{title}
+		}
+		segs = append(segs, seg)
 	}
-	return strings.Join(allText, "\n"), nil
+	return segs, nil
 }
 
-// extractTextFromXML 从 raw XML 中提取所有 <textTag> 节点文本，
-// 并在每遇到一次 endTag 时插入换行。
-func extractTextFromXML(raw []byte, textTag, endTag string) string {
+// slideText 返回幻灯片标题与全部文字；<a:p> 结束时换行，标题来自 type 为 title / ctrTitle 的占位符
+func slideText(raw []byte) (title, text string) {
 	dec := xml.NewDecoder(bytes.NewReader(raw))
-	var sb strings.Builder
-
+	var (
+		sb, tb  strings.Builder
+		inText  bool
+		isTitle bool // 当前 <p:sp> 是否是标题占位符
+	)
 	for {
 		tok, err := dec.Token()
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			// 出错就先返回已累积文本
-			return sb.String()
+			break
 		}
 		switch t := tok.(type) {
-		case xml.CharData:
-			// 直接写入文本节点内容
-			sb.WriteString(string(t))
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sp":
+				isTitle = false
+			case "ph":
+				typ := xmlAttr(t, "type")
+				isTitle = typ == "title" || typ == "ctrTitle"
+			case "t":
+				inText = true
+			}
 		case xml.EndElement:
-			if t.Name.Local == endTag {
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
 				sb.WriteString("\n")
+				if isTitle {
+					tb.WriteString(" ")
+				}
+			case "sp":
+				isTitle = false
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+				if isTitle {
+					tb.Write(t)
+				}
 			}
 		}
 	}
-	return sb.String()
+	return strings.TrimSpace(tb.String()), sb.String()
+}
+
+// readZipFile 读取 zip 内指定文件，不存在时返回 nil
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if path.Clean(f.Name) == name {
+			return readZip(f)
+		}
+	}
+	return nil, nil
+}
+
+func readZip(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// xmlAttr 按本地名读取属性（忽略命名空间前缀）
+func xmlAttr(e xml.StartElement, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
 }
 
 func init() {
@@ -0,0 +1,24 @@
+package extractor
+
+import "os"
+
+// mdExt Markdown 原样读入，按标题分节
+type mdExt struct{}
+
+func (m mdExt) Extract(p string) (string, error) {
+	b, err := os.ReadFile(p)
+	return string(b), err
+}
+
+func (m mdExt) ExtractSegments(p string) ([]Segment, error) {
+	text, err := m.Extract(p)
+	if err != nil {
+		return nil, err
+	}
+	return MarkdownSegments(text), nil
+}
+
+func init() {
+	Register(".md", mdExt{})
+	Register(".markdown", mdExt{})
+}
@@ -2,8 +2,10 @@ package extractor
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os/exec"
+	"strings"
 
 	"github.com/ledongthuc/pdf"
 )
@@ -29,6 +31,38 @@ func (textPDF) Extract(p string) (string, error) {
 	return buf.String(), nil
 }
 
+// ExtractSegments 逐页提取，每页一个分段，便于回答引用页码
+func (textPDF) ExtractSegments(p string) ([]Segment, error) {
+	f, reader, err := pdf.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var segs []Segment
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+		text, err := page.GetPlainText(fonts)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 页: %w", i, err)
+		}
+		if strings.TrimSpace(text) != "" {
+			segs = append(segs, Segment{Text: text, Page: i})
+		}
+	}
+	return segs, nil
+}
+
 // scanPDF：扫描 PDF 调用 tesseract CLI 做 OCR
 type scanPDF struct{}
 
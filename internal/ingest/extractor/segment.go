@@ -0,0 +1,88 @@
+package extractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Segment 带位置信息的一段文本，切片后这些字段会随每个片段写进向量库 payload
+type Segment struct {
+	Text     string
+	Page     int      // PDF 页码，从 1 开始；0 表示未知
+	Slide    int      // PPT 幻灯片序号，从 1 开始
+	Headings []string // 所在章节的标题路径，如 ["3 实验原理", "3.2 巨磁阻效应"]
+	Style    string   // 段落样式，如 docx 的 Heading2 / ListParagraph
+}
+
+// SegmentExtractor 能返回结构化分段的提取器；只实现 Extractor 的格式按整篇一个分段处理
+type SegmentExtractor interface {
+	Extractor
+	ExtractSegments(path string) ([]Segment, error)
+}
+
+// Segments 用 ex 提取分段；ex 不支持分段时退化为整篇文本一个分段
+func Segments(ex Extractor, path string) ([]Segment, error) {
+	if se, ok := ex.(SegmentExtractor); ok {
+		return se.ExtractSegments(path)
+	}
+	text, err := ex.Extract(path)
+	if err != nil {
+		return nil, err
+	}
+	return []Segment{{Text: text}}, nil
+}
+
+// joinSegments 把分段拼回纯文本，供 Extract 复用 ExtractSegments 的实现
+func joinSegments(segs []Segment) string {
+	parts := make([]string, len(segs))
+	for i, s := range segs {
+		parts[i] = s.Text
+	}
+	return strings.Join(parts, "\n")
+}
+
+// headingPath 维护当前的标题层级路径
+type headingPath []string
+
+// enter 进入 level 级标题（从 1 开始），截掉同级及更深的旧标题
+func (h headingPath) enter(level int, title string) headingPath {
+	if level < 1 {
+		level = 1
+	}
+	if level > len(h)+1 {
+		level = len(h) + 1 // 跳级的标题（# 后直接 ###）挂在当前路径末尾
+	}
+	next := append(headingPath(nil), h[:level-1]...)
+	return append(next, title)
+}
+
+// mdHeadingRe Markdown ATX 标题
+var mdHeadingRe = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// MarkdownSegments 按 Markdown 标题把文本分成章节，每节带上完整的标题路径；代码块内的 # 不算标题
+func MarkdownSegments(text string) []Segment {
+	var (
+		segs    []Segment
+		path    headingPath
+		lines   []string
+		inFence bool
+	)
+	flush := func() {
+		if t := strings.TrimSpace(strings.Join(lines, "\n")); t != "" {
+			segs = append(segs, Segment{Text: t, Headings: path})
+		}
+		lines = nil
+	}
+	for _, l := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(l), "```") {
+			inFence = !inFence
+		}
+		if m := mdHeadingRe.FindStringSubmatch(l); m != nil && !inFence {
+			flush()
+			path = path.enter(len(m[1]), m[2])
+		}
+		lines = append(lines, l)
+	}
+	flush()
+	return segs
+}
@@ -3,93 +3,340 @@ package ingest
 import (
 	"context"
 	"fmt"
-	"github.com/google/uuid"
 	"github.com/iammm0/physics-llm/internal/config"
 	"github.com/iammm0/physics-llm/internal/ingest/extractor"
-	"github.com/iammm0/physics-llm/internal/ollama"
+	"github.com/iammm0/physics-llm/internal/llm"
+	"github.com/iammm0/physics-llm/internal/llm/provider"
+	"github.com/iammm0/physics-llm/internal/retrieval"
 	"github.com/iammm0/physics-llm/internal/store"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
-func extractText(path string) (string, error) {
+// extractSegments 提取带页码 / 标题等位置信息的分段；不支持分段的格式整篇作为一个分段
+func extractSegments(path string) ([]extractor.Segment, error) {
 	ext := strings.ToLower(filepath.Ext(path))
 	if ex, ok := extractor.Get(ext); ok {
-		return ex.Extract(path)
+		return extractor.Segments(ex, path)
 	}
-	// 回退：当普通 UTF-8 文本
+	// 纯文本格式（plainTextExts）直接按 UTF-8 读取；其它格式已在 Walk 中按 Supported 过滤
 	b, err := os.ReadFile(path)
-	return string(b), err
+	if err != nil {
+		return nil, err
+	}
+	return []extractor.Segment{{Text: string(b)}}, nil
 }
 
-// chunkText 按指定长度 + 重叠切分文本
-func chunkText(text string, size, overlap int) []string {
-	var chunks []string
-	for start := 0; start < len(text); start += size - overlap {
-		end := start + size
-		if end > len(text) {
-			end = len(text)
-		}
-		chunks = append(chunks, strings.TrimSpace(text[start:end]))
-		if end == len(text) {
-			break
-		}
+// plainTextExts 没有专门提取器、按 UTF-8 文本直接读取的扩展名
+var plainTextExts = map[string]bool{".txt": true, ".csv": true, ".tex": true}
+
+// Supported 判断文件能否被导入：在提取器注册表中，或属于纯文本格式
+func Supported(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if _, ok := extractor.Get(ext); ok {
+		return true
+	}
+	return plainTextExts[ext]
+}
+
+// walkOptions 从配置读取遍历选项
+func walkOptions(cfg *config.Config) WalkOptions {
+	return WalkOptions{
+		Include:        cfg.IngestInclude,
+		Exclude:        cfg.IngestExclude,
+		FollowSymlinks: cfg.IngestFollowSymlinks,
+		IncludeHidden:  cfg.IngestIncludeHidden,
+	}
+}
+
+// Action 对某个文件要做的处理
+type Action string
+
+const (
+	ActionAdd    Action = "add"    // 清单中没有，新导入
+	ActionUpdate Action = "update" // 内容已变化，删旧点后重导
+	ActionSkip   Action = "skip"   // 内容未变化
+	ActionRemove Action = "remove" // 文件已从目录删除，清理其点
+)
+
+// PlanItem 同步计划中的一项
+type PlanItem struct {
+	Source string
+	Path   string // ActionRemove 时为空
+	Action Action
+	state  FileState // 当前磁盘状态（ActionRemove 时为清单中的旧状态）
+	meta   Metadata  // 检索标签，ActionRemove 时为空
+}
+
+// Report 一次导入的统计
+type Report struct {
+	Ingested int
+	Skipped  int
+	Removed  int
+	Failed   int
+}
+
+// Ingester 负责把知识文件同步到向量库，API 启动流程与 cmd/ingest 共用
+type Ingester struct {
+	cfg      *config.Config
+	embedder llm.Embedder
+	model    string // Embedding 模型名，写入 payload 与清单
+	db       store.VectorStore
+	manifest *Manifest
+	keywords *retrieval.Index // 与向量同步维护的 BM25 关键词索引
+	chunker  Chunker
+	embedSem chan struct{} // 限制所有 worker 同时在途的 Embedding 请求数
+}
+
+// New 创建 Ingester 并加载导入清单
+func New(cfg *config.Config) (*Ingester, error) {
+	manifest, err := LoadManifest(cfg.IngestManifest)
+	if err != nil {
+		return nil, err
+	}
+	keywords, err := retrieval.LoadIndex(cfg.KeywordIndex)
+	if err != nil {
+		return nil, err
+	}
+	chunker, err := NewChunker(cfg)
+	if err != nil {
+		return nil, err
+	}
+	embedder, err := provider.NewEmbedder(cfg)
+	if err != nil {
+		return nil, err
 	}
-	return chunks
+	db, err := store.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Ingester{
+		cfg:      cfg,
+		embedder: embedder,
+		model:    provider.EmbedModel(cfg),
+		db:       db,
+		manifest: manifest,
+		keywords: keywords,
+		chunker:  chunker,
+		embedSem: make(chan struct{}, max(cfg.EmbedConcurrency, 1)),
+	}, nil
 }
 
-// Run 扫描 cfg.KnowledgeDir 下所有文件，切片、Embedding 并 Upsert 到 Qdrant
+// Run 递归扫描 cfg.KnowledgeDir 下所有文件，切片、Embedding 并 Upsert 到 Qdrant。
+// 借助导入清单做增量：未变化的文件直接跳过，变化的文件先删旧点再写入，已删除的文件清理其点。
 func Run(ctx context.Context, cfg *config.Config) error {
-	llmClient := ollama.NewClient(cfg)
-	dbClient := store.NewClient(cfg)
+	in, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = in.Sync(ctx)
+	return err
+}
+
+// Manifest 返回当前导入清单
+func (in *Ingester) Manifest() *Manifest { return in.manifest }
 
-	// 1. 列出所有知识文件
-	pattern := filepath.Join(cfg.KnowledgeDir, "*")
-	files, err := filepath.Glob(pattern)
+// Keywords 返回关键词索引，检索时应与导入共用这一实例
+func (in *Ingester) Keywords() *retrieval.Index { return in.keywords }
+
+// Store 返回向量库，检索时应与导入共用这一实例（embedded 后端的数据常驻内存）
+func (in *Ingester) Store() store.VectorStore { return in.db }
+
+// Plan 对比知识库目录与导入清单，计算每个文件的处理方式，不做任何写入
+func (in *Ingester) Plan() ([]PlanItem, error) {
+	files, unsupported, err := Walk(in.cfg.KnowledgeDir, walkOptions(in.cfg))
 	if err != nil {
-		return fmt.Errorf("扫描知识库目录失败: %w", err)
+		return nil, err
 	}
-	log.Printf("发现 %d 个知识文件\n", len(files))
+	log.Printf("发现 %d 个知识文件，跳过 %d 个不支持的格式\n", len(files), len(unsupported))
 
-	// 2. 对每个文件处理
-	for _, file := range files {
-		text, err := extractText(file)
+	var plan []PlanItem
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		seen[f.Source] = true
+		item, err := in.planFile(f, false)
 		if err != nil {
-			log.Printf("跳过 %s: %v\n", file, err)
+			log.Printf("跳过 %s: %v\n", f.Path, err)
 			continue
 		}
+		plan = append(plan, item)
+	}
+
+	var removed []PlanItem
+	for source, st := range in.manifest.Snapshot() {
+		if !seen[source] {
+			removed = append(removed, PlanItem{Source: source, Action: ActionRemove, state: st})
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Source < removed[j].Source })
+	return append(plan, removed...), nil
+}
 
-		// 3. 文本切片
-		chunks := chunkText(text, cfg.ChunkSize, cfg.ChunkOverlap)
-		log.Printf("文件 %s 切成 %d 段\n", filepath.Base(file), len(chunks))
+// planFile 判断单个文件的处理方式；force 为 true 时已导入且未变化的文件也重导
+func (in *Ingester) planFile(f File, force bool) (PlanItem, error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return PlanItem{}, err
+	}
+	meta, err := loadMetadata(in.cfg.KnowledgeDir, f.Source)
+	if err != nil {
+		return PlanItem{}, err
+	}
+	item := PlanItem{Source: f.Source, Path: f.Path, meta: meta}
+	prev, known := in.manifest.Get(f.Source)
+	// 切片参数、元数据或 Embedding 模型变了，旧切片全部作废，等同强制重导
+	force = force || (known && (prev.Chunker != chunkerFingerprint(in.cfg) || prev.Meta != meta.fingerprint() ||
+		prev.EmbedModel != in.model))
+	// 关键词索引缺了这个文件（如索引文件被删或是升级前导入的），需要重导补齐
+	force = force || (known && prev.Chunks > 0 && !in.keywords.Has(f.Source))
 
-		// 4. Embedding + 构造 Point
-		var points []store.Point
-		for idx, chunk := range chunks {
-			vec, err := llmClient.Embeddings(chunk)
-			if err != nil {
-				return fmt.Errorf("生成 Embedding 失败 (%s 段 %d): %w", file, idx, err)
-			}
-			id := uuid.New().String()
-			points = append(points, store.Point{
-				ID:     id,
-				Vector: vec,
-				Payload: map[string]interface{}{
-					"text":   chunk,
-					"source": filepath.Base(file),
-					"index":  idx,
-				},
-			})
+	// 大小与修改时间都没变，视为未变化，省去读全文件算哈希
+	if known && !force && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+		item.Action, item.state = ActionSkip, prev
+		return item, nil
+	}
+	hash, err := hashFile(f.Path)
+	if err != nil {
+		return PlanItem{}, err
+	}
+	item.state = FileState{Hash: hash, Size: info.Size(), ModTime: info.ModTime(), Chunks: prev.Chunks,
+		Chunker: prev.Chunker, Meta: prev.Meta, EmbedModel: prev.EmbedModel, IngestedAt: prev.IngestedAt}
+	switch {
+	case !known:
+		item.Action = ActionAdd
+	case force || prev.Hash != hash:
+		item.Action = ActionUpdate
+	default:
+		item.Action = ActionSkip // 只是被 touch 过，内容相同
+	}
+	return item, nil
+}
+
+// Sync 按 Plan 执行一次增量同步
+func (in *Ingester) Sync(ctx context.Context) (Report, error) {
+	plan, err := in.Plan()
+	if err != nil {
+		return Report{}, err
+	}
+	rep, err := in.Apply(ctx, plan)
+	if err != nil {
+		return rep, err
+	}
+	log.Printf("知识库导入完成：导入 %d，未变化 %d，清理 %d，失败 %d\n",
+		rep.Ingested, rep.Skipped, rep.Removed, rep.Failed)
+	return rep, nil
+}
+
+// Apply 执行同步计划，等价于不带进度回调的 Execute
+func (in *Ingester) Apply(ctx context.Context, plan []PlanItem) (Report, error) {
+	return in.Execute(ctx, plan, nil)
+}
+
+// AddPath 导入指定文件或目录（必须位于知识库目录内）；force 为 true 时忽略清单强制重导
+func (in *Ingester) AddPath(ctx context.Context, path string, force bool) (Report, error) {
+	plan, err := in.PlanPath(path, force)
+	if err != nil {
+		return Report{}, err
+	}
+	return in.Apply(ctx, plan)
+}
+
+// PlanPath 为知识库目录内的指定文件或目录生成同步计划
+func (in *Ingester) PlanPath(path string, force bool) ([]PlanItem, error) {
+	root, err := filepath.Abs(in.cfg.KnowledgeDir)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s 不在知识库目录 %s 内", path, in.cfg.KnowledgeDir)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	if info.IsDir() {
+		var unsupported []string
+		if files, unsupported, err = Walk(abs, walkOptions(in.cfg)); err != nil {
+			return nil, err
+		}
+		for _, s := range unsupported {
+			log.Printf("跳过 %s: 不支持的格式\n", s)
 		}
+		for i := range files {
+			files[i].Source = filepath.ToSlash(filepath.Join(rel, files[i].Source))
+		}
+	} else {
+		if IsSidecar(abs) {
+			return nil, fmt.Errorf("%s 是元数据旁注文件，不能单独导入", path)
+		}
+		if !Supported(abs) {
+			return nil, fmt.Errorf("%s 的格式不支持", path)
+		}
+		files = []File{{Path: abs, Source: filepath.ToSlash(rel)}}
+	}
 
-		// 5. 批量 Upsert
-		if err := dbClient.Upsert(ctx, points); err != nil {
-			return fmt.Errorf("upsert 到 Qdrant 失败 (%s): %w", file, err)
+	var plan []PlanItem
+	for _, f := range files {
+		item, err := in.planFile(f, force)
+		if err != nil {
+			log.Printf("跳过 %s: %v\n", f.Path, err)
+			continue
 		}
+		plan = append(plan, item)
 	}
+	return plan, nil
+}
+
+// Reindex 忽略清单，强制重导知识库目录下的全部文件，并清理已删除文件
+func (in *Ingester) Reindex(ctx context.Context) (Report, error) {
+	plan, err := in.PlanReindex()
+	if err != nil {
+		return Report{}, err
+	}
+	return in.Apply(ctx, plan)
+}
 
-	log.Println("知识库导入完成")
+// PlanReindex 与 Plan 相同，但把未变化的文件也改为重导
+func (in *Ingester) PlanReindex() ([]PlanItem, error) {
+	plan, err := in.Plan()
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range plan {
+		if item.Action == ActionSkip {
+			if plan[i], err = in.planFile(File{Path: item.Path, Source: item.Source}, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return plan, nil
+}
+
+// PlanRemove 生成清理单个 source 的计划
+func PlanRemove(source string) []PlanItem {
+	return []PlanItem{{Source: source, Action: ActionRemove}}
+}
+
+// Remove 删除某个 source 的全部向量并移出清单（不删除磁盘文件）
+func (in *Ingester) Remove(ctx context.Context, source string) error {
+	if err := in.db.Delete(ctx, store.SourceFilter(source)); err != nil {
+		return fmt.Errorf("删除 %s 的旧向量失败: %w", source, err)
+	}
+	if err := in.keywords.Delete(source); err != nil {
+		return fmt.Errorf("更新关键词索引失败: %w", err)
+	}
+	if err := in.manifest.Delete(source); err != nil {
+		return fmt.Errorf("保存导入清单失败: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,275 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrJobNotFound 任务不存在
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrQueueFull 排队的任务已达上限，新任务直接标记为 failed
+var ErrQueueFull = errors.New("任务队列已满，请稍后重试")
+
+// JobStatus 任务状态
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"    // 全部文件成功
+	JobPartial JobStatus = "partial" // 部分文件失败，其余已完成
+	JobFailed  JobStatus = "failed"  // 无法生成计划或被中断
+)
+
+// Job 一个后台导入任务
+type Job struct {
+	ID         string         `json:"id"`
+	Kind       string         `json:"kind"`   // upload / reindex / delete / sync
+	Target     string         `json:"target"` // 作用的 source 或路径
+	Status     JobStatus      `json:"status"`
+	Files      []FileProgress `json:"files"`
+	Report     Report         `json:"report"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	StartedAt  time.Time      `json:"started_at,omitempty"`
+	FinishedAt time.Time      `json:"finished_at,omitempty"`
+}
+
+// PlanFunc 在任务开始执行时生成同步计划，保证看到的是执行时刻的磁盘状态
+type PlanFunc func() ([]PlanItem, error)
+
+/*
+Jobs 后台导入任务队列
+
+任务按提交顺序逐个执行（同一时刻只有一个任务在写 Qdrant 和清单），
+任务内部的文件由 Ingester.Execute 的 worker 池并发处理。
+每个任务的状态在文件阶段变化时写入 dir/{id}.json，重启后仍可查询；
+重启时仍处于 queued / running 的任务标记为 failed。
+*/
+type Jobs struct {
+	in  *Ingester
+	dir string
+
+	mu    sync.RWMutex
+	jobs  map[string]*Job
+	index map[string]map[string]int // job ID → source → Files 下标
+	queue chan queuedJob
+}
+
+// maxQueuedJobs 等待执行的任务上限，超出时 Submit 不阻塞调用方
+const maxQueuedJobs = 256
+
+type queuedJob struct {
+	job  *Job
+	plan PlanFunc
+}
+
+// NewJobs 创建任务队列并加载历史任务记录，需调用 Start 开始消费
+func NewJobs(in *Ingester, dir string) (*Jobs, error) {
+	j := &Jobs{
+		in:    in,
+		dir:   dir,
+		jobs:  map[string]*Job{},
+		index: map[string]map[string]int{},
+		queue: make(chan queuedJob, maxQueuedJobs),
+	}
+	if dir == "" {
+		return j, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建任务目录失败: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var job Job
+		if err := json.Unmarshal(b, &job); err != nil {
+			log.Printf("跳过损坏的任务记录 %s: %v\n", f, err)
+			continue
+		}
+		if job.Status == JobQueued || job.Status == JobRunning {
+			job.Status, job.Error = JobFailed, "服务重启，任务中断"
+			j.save(&job)
+		}
+		j.jobs[job.ID] = &job
+	}
+	return j, nil
+}
+
+// Start 在后台消费队列，ctx 取消后退出
+func (j *Jobs) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case q := <-j.queue:
+				j.run(ctx, q)
+			}
+		}
+	}()
+}
+
+// Submit 提交任务并立即返回其快照；队列已满时任务记为 failed，并返回 ErrQueueFull
+func (j *Jobs) Submit(kind, target string, plan PlanFunc) (Job, error) {
+	job := &Job{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Target:    target,
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+	}
+	j.mu.Lock()
+	j.jobs[job.ID] = job
+	j.save(job)
+	snapshot := job.clone()
+	j.mu.Unlock()
+
+	select {
+	case j.queue <- queuedJob{job: job, plan: plan}:
+		return snapshot, nil
+	default:
+		j.finish(job, Report{}, ErrQueueFull)
+		failed, _ := j.Get(job.ID)
+		return failed, ErrQueueFull
+	}
+}
+
+// Get 返回任务快照
+func (j *Jobs) Get(id string) (Job, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return job.clone(), nil
+}
+
+// List 按创建时间倒序返回最近 limit 个任务（不含文件明细）
+func (j *Jobs) List(limit int) []Job {
+	j.mu.RLock()
+	out := make([]Job, 0, len(j.jobs))
+	for _, job := range j.jobs {
+		cp := *job
+		cp.Files = nil
+		out = append(out, cp)
+	}
+	j.mu.RUnlock()
+
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.After(out[b].CreatedAt) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func (j *Jobs) run(ctx context.Context, q queuedJob) {
+	j.update(q.job, func(job *Job) {
+		job.Status, job.StartedAt = JobRunning, time.Now()
+	})
+
+	plan, err := q.plan()
+	if err != nil {
+		j.finish(q.job, Report{}, err)
+		return
+	}
+
+	rep, err := j.in.Execute(ctx, plan, func(fp FileProgress) {
+		j.progress(q.job, fp)
+	})
+	j.finish(q.job, rep, err)
+}
+
+// progress 更新单个文件进度；只有阶段变化时才落盘，Embedding 计数只在内存里刷新
+func (j *Jobs) progress(job *Job, fp FileProgress) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	idx := j.index[job.ID]
+	if idx == nil {
+		idx = map[string]int{}
+		j.index[job.ID] = idx
+	}
+	i, ok := idx[fp.Source]
+	if !ok {
+		i = len(job.Files)
+		idx[fp.Source] = i
+		job.Files = append(job.Files, fp)
+		j.save(job)
+		return
+	}
+	changed := job.Files[i].Stage != fp.Stage
+	job.Files[i] = fp
+	if changed {
+		j.save(job)
+	}
+}
+
+func (j *Jobs) finish(job *Job, rep Report, err error) {
+	j.update(job, func(job *Job) {
+		job.Report, job.FinishedAt = rep, time.Now()
+		switch {
+		case err != nil:
+			job.Status, job.Error = JobFailed, err.Error()
+		case rep.Failed > 0:
+			job.Status, job.Error = JobPartial, fmt.Sprintf("%d 个文件失败", rep.Failed)
+		default:
+			job.Status = JobDone
+		}
+	})
+	j.mu.Lock()
+	delete(j.index, job.ID)
+	j.mu.Unlock()
+
+	if err != nil {
+		log.Printf("任务 %s (%s %s) 失败: %v\n", job.ID, job.Kind, job.Target, err)
+	}
+}
+
+func (j *Jobs) update(job *Job, fn func(*Job)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(job)
+	j.save(job)
+}
+
+// save 调用方需持有写锁；持久化失败只记日志，不影响任务本身
+func (j *Jobs) save(job *Job) {
+	if j.dir == "" {
+		return
+	}
+	b, err := json.MarshalIndent(job, "", "  ")
+	if err == nil {
+		path := filepath.Join(j.dir, job.ID+".json")
+		if err = os.WriteFile(path+".tmp", b, 0o644); err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+	}
+	if err != nil {
+		log.Printf("保存任务 %s 失败: %v\n", job.ID, err)
+	}
+}
+
+func (job *Job) clone() Job {
+	cp := *job
+	cp.Files = append([]FileProgress(nil), job.Files...)
+	return cp
+}
@@ -0,0 +1,179 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// pointNamespace 生成确定性点 ID 的 UUIDv5 命名空间，改动会导致所有 ID 变化
+var pointNamespace = uuid.MustParse("5b6c1e0a-3f47-4d5e-9a4c-6f1f0c2b8e17")
+
+// FileState 记录某个知识文件上次导入时的状态
+type FileState struct {
+	Hash       string    `json:"hash"` // 文件内容 sha256
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	Chunks     int       `json:"chunks"`
+	Chunker    string    `json:"chunker"`        // 导入时的切片参数指纹
+	Meta       string    `json:"meta,omitempty"` // 导入时的元数据指纹
+	EmbedModel string    `json:"embed_model"`    // 导入时的 Embedding 模型
+	IngestedAt time.Time `json:"ingested_at"`
+}
+
+// Manifest 以 source 为键记录已导入文件，持久化为 JSON；方法均可并发调用。
+// 与关键词索引一样，每次读写前检查文件修改时间，被另一进程（cmd/ingest、切换版本）改写过就重新加载。
+type Manifest struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time // 最近一次加载或写入时文件的修改时间
+	files   map[string]FileState
+}
+
+// manifestFile 清单文件的磁盘格式
+type manifestFile struct {
+	Files map[string]FileState `json:"files"`
+}
+
+// LoadManifest 读取清单文件，不存在时返回空清单
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, files: map[string]FileState{}}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// load 从磁盘重新读取；调用方需持有写锁（LoadManifest 除外）
+func (m *Manifest) load() error {
+	info, err := os.Stat(m.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+	var mf manifestFile
+	if err := json.Unmarshal(b, &mf); err != nil {
+		return fmt.Errorf("解析导入清单 %s 失败: %w", m.path, err)
+	}
+	m.files = map[string]FileState{}
+	if mf.Files != nil {
+		m.files = mf.Files
+	}
+	m.modTime = info.ModTime()
+	return nil
+}
+
+// refresh 文件被其它进程改写过时重新加载；调用方需持有写锁
+func (m *Manifest) refresh() error {
+	info, err := os.Stat(m.path)
+	if err != nil || info.ModTime().Equal(m.modTime) {
+		return nil
+	}
+	return m.load()
+}
+
+// Get 返回某个 source 的导入状态
+func (m *Manifest) Get(source string) (FileState, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.refresh()
+	st, ok := m.files[source]
+	return st, ok
+}
+
+// Put 记录某个 source 的导入状态并立即落盘
+func (m *Manifest) Put(source string, st FileState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		return err
+	}
+	m.files[source] = st
+	return m.save()
+}
+
+// Delete 移除某个 source 并立即落盘
+func (m *Manifest) Delete(source string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		return err
+	}
+	delete(m.files, source)
+	return m.save()
+}
+
+// Snapshot 返回当前清单的副本
+func (m *Manifest) Snapshot() map[string]FileState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.refresh()
+	out := make(map[string]FileState, len(m.files))
+	for k, v := range m.files {
+		out[k] = v
+	}
+	return out
+}
+
+// save 写临时文件后 rename，避免中途崩溃留下半截清单；调用方需持有写锁
+func (m *Manifest) save() error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(manifestFile{Files: m.files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(m.path); err == nil {
+		m.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// hashFile 计算文件内容的 sha256
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DocumentID 由 source 派生的稳定文档 ID，供管理接口引用文件
+func DocumentID(source string) string {
+	return uuid.NewSHA1(pointNamespace, []byte("doc:"+source)).String()
+}
+
+// pointID 由 source + 切片序号 + 切片内容哈希派生，同样的输入永远得到同一个 ID，
+// 重复导入只会覆盖而不会产生重复点
+func pointID(source string, idx int, chunk string) string {
+	sum := sha256.Sum256([]byte(chunk))
+	name := fmt.Sprintf("%s#%d#%s", source, idx, hex.EncodeToString(sum[:]))
+	return uuid.NewSHA1(pointNamespace, []byte(name)).String()
+}
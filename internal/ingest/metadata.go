@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 元数据旁注文件：目录级的 _meta.yaml 作用于该目录及其子目录，文件级的 <文件名>.meta.yaml 只作用于该文件
+const (
+	dirMetaFile    = "_meta.yaml"
+	fileMetaSuffix = ".meta.yaml"
+)
+
+// IsSidecar 判断是否是元数据旁注文件，这类文件本身不导入
+func IsSidecar(name string) bool {
+	base := path.Base(filepath.ToSlash(name))
+	return base == dirMetaFile || strings.HasSuffix(base, fileMetaSuffix)
+}
+
+/*
+Metadata 片段的检索标签，写进 payload 供过滤使用
+
+默认从目录结构推断：knowledge/<course>/<experiment>/…/文件，只有一层目录时只有 course；
+再依次用根目录到文件所在目录的 _meta.yaml、文件自己的 .meta.yaml 覆盖，越近的优先。
+language 未指定时按正文中汉字的比例判断为 zh 或 en。
+*/
+type Metadata struct {
+	Course     string   `yaml:"course" json:"course,omitempty"`
+	Experiment string   `yaml:"experiment" json:"experiment,omitempty"`
+	Language   string   `yaml:"language" json:"language,omitempty"`
+	Tags       []string `yaml:"tags" json:"tags,omitempty"`
+}
+
+// loadMetadata 解析 source（相对 root 的路径）的元数据
+func loadMetadata(root, source string) (Metadata, error) {
+	var m Metadata
+	dirs := strings.Split(path.Dir(source), "/")
+	if dirs[0] == "." {
+		dirs = nil
+	}
+	if len(dirs) > 0 {
+		m.Course = dirs[0]
+	}
+	if len(dirs) > 1 {
+		m.Experiment = dirs[1]
+	}
+
+	files := []string{filepath.Join(root, dirMetaFile)}
+	for i := range dirs {
+		files = append(files, filepath.Join(root, filepath.FromSlash(path.Join(dirs[:i+1]...)), dirMetaFile))
+	}
+	files = append(files, filepath.Join(root, filepath.FromSlash(source))+fileMetaSuffix)
+	for _, f := range files {
+		if err := m.overlay(f); err != nil {
+			return Metadata{}, err
+		}
+	}
+	return m, nil
+}
+
+// overlay 用旁注文件中非空的字段覆盖 m；文件不存在时不做任何事
+func (m *Metadata) overlay(file string) error {
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var o Metadata
+	if err := yaml.Unmarshal(b, &o); err != nil {
+		return fmt.Errorf("解析元数据 %s 失败: %w", file, err)
+	}
+	if o.Course != "" {
+		m.Course = o.Course
+	}
+	if o.Experiment != "" {
+		m.Experiment = o.Experiment
+	}
+	if o.Language != "" {
+		m.Language = o.Language
+	}
+	if o.Tags != nil {
+		m.Tags = o.Tags
+	}
+	return nil
+}
+
+// fingerprint 元数据的指纹，记录在清单里；旁注文件修改后对应文件会被视为需要重导
+func (m Metadata) fingerprint() string {
+	b, _ := json.Marshal(m)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// apply 把元数据写入 payload，空字段不写
+func (m Metadata) apply(payload map[string]interface{}, fileType string) {
+	for key, v := range map[string]string{
+		"course":     m.Course,
+		"experiment": m.Experiment,
+		"language":   m.Language,
+		"file_type":  fileType,
+	} {
+		if v != "" {
+			payload[key] = v
+		}
+	}
+	if len(m.Tags) > 0 {
+		payload["tags"] = m.Tags
+	}
+}
+
+// fileType 文件类型标签：小写扩展名，不含点；.scan.pdf 等多段扩展名只取最后一段
+func fileType(source string) string {
+	return strings.TrimPrefix(strings.ToLower(path.Ext(source)), ".")
+}
+
+// detectLanguage 汉字占字母类字符三成以上视为中文
+func detectLanguage(text string) string {
+	var han, letters int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+			letters++
+		case unicode.IsLetter(r):
+			letters++
+		}
+	}
+	switch {
+	case letters == 0:
+		return ""
+	case han*10 >= letters*3:
+		return "zh"
+	default:
+		return "en"
+	}
+}
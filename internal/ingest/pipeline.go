@@ -0,0 +1,313 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iammm0/physics-llm/internal/ingest/extractor"
+	"github.com/iammm0/physics-llm/internal/retrieval"
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// Stage 单个文件在流水线中所处的阶段
+type Stage string
+
+const (
+	StageQueued     Stage = "queued"
+	StageExtracting Stage = "extracting"
+	StageEmbedding  Stage = "embedding"
+	StageUpserting  Stage = "upserting"
+	StageRemoving   Stage = "removing"
+	StageSkipped    Stage = "skipped"
+	StageDone       Stage = "done"
+	StageFailed     Stage = "failed"
+)
+
+const (
+	retryBaseDelay = 2 * time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// FileProgress 单个文件的处理进度
+type FileProgress struct {
+	Source   string    `json:"source"`
+	Action   Action    `json:"action"`
+	Stage    Stage     `json:"stage"`
+	Attempts int       `json:"attempts"`
+	Chunks   int       `json:"chunks"`   // 切片总数，提取完成后才有值
+	Embedded int       `json:"embedded"` // 已生成向量的切片数
+	Error    string    `json:"error,omitempty"`
+	Updated  time.Time `json:"updated_at"`
+}
+
+// ProgressFunc 接收文件进度快照；会被多个 worker 并发调用
+type ProgressFunc func(FileProgress)
+
+// permanentError 重试也不会成功的错误（如文件无法解析），不再重试
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+/*
+Execute 用 cfg.IngestWorkers 个 worker 并发执行同步计划
+
+单个文件失败不会中断其余文件：Embedding / Upsert 等暂时性错误按指数退避重试
+cfg.IngestRetries 次，仍失败则记为 failed，留待下次同步；文件无法解析则直接 failed。
+每个文件完成后立即写清单，中途中断下次可以接着来。只有 ctx 取消或清单写盘失败才返回 error。
+*/
+func (in *Ingester) Execute(ctx context.Context, plan []PlanItem, progress ProgressFunc) (Report, error) {
+	if progress == nil {
+		progress = func(FileProgress) {}
+	}
+	for _, item := range plan {
+		progress(FileProgress{Source: item.Source, Action: item.Action, Stage: StageQueued, Updated: time.Now()})
+	}
+
+	workers := in.cfg.IngestWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		rep      Report
+		fatalErr error
+		wg       sync.WaitGroup
+	)
+	items := make(chan PlanItem)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				result, err := in.runItem(ctx, item, progress)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					if fatalErr == nil {
+						fatalErr = err
+					}
+				case result == StageSkipped:
+					rep.Skipped++
+				case result == StageFailed:
+					rep.Failed++
+				case item.Action == ActionRemove:
+					rep.Removed++
+				default:
+					rep.Ingested++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, item := range plan {
+		select {
+		case <-ctx.Done():
+			break feed
+		case items <- item:
+		}
+	}
+	close(items)
+	wg.Wait()
+
+	if fatalErr == nil {
+		fatalErr = ctx.Err()
+	}
+	return rep, fatalErr
+}
+
+// runItem 处理一个计划项并返回其最终阶段；返回 error 表示需要中止整个计划
+func (in *Ingester) runItem(ctx context.Context, item PlanItem, progress ProgressFunc) (Stage, error) {
+	fp := FileProgress{Source: item.Source, Action: item.Action}
+	report := func(stage Stage) {
+		fp.Stage, fp.Updated = stage, time.Now()
+		progress(fp)
+	}
+
+	switch item.Action {
+	case ActionSkip:
+		// 刷新修改时间，下次不用再算哈希
+		if prev, _ := in.manifest.Get(item.Source); !prev.ModTime.Equal(item.state.ModTime) {
+			if err := in.manifest.Put(item.Source, item.state); err != nil {
+				return StageFailed, fmt.Errorf("保存导入清单失败: %w", err)
+			}
+		}
+		report(StageSkipped)
+		return StageSkipped, nil
+
+	case ActionRemove:
+		report(StageRemoving)
+	}
+
+	for {
+		fp.Attempts++
+		var err error
+		if item.Action == ActionRemove {
+			err = in.Remove(ctx, item.Source)
+		} else {
+			err = in.ingestFile(ctx, item, &fp, report)
+		}
+		if err == nil {
+			report(StageDone)
+			return StageDone, nil
+		}
+		if ctx.Err() != nil {
+			return StageFailed, ctx.Err()
+		}
+
+		var perm permanentError
+		if errors.As(err, &perm) || fp.Attempts > in.cfg.IngestRetries {
+			fp.Error = err.Error()
+			report(StageFailed)
+			log.Printf("文件 %s 处理失败（第 %d 次尝试）: %v\n", item.Source, fp.Attempts, err)
+			return StageFailed, nil
+		}
+
+		delay := retryBaseDelay << (fp.Attempts - 1)
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+		log.Printf("文件 %s 处理失败，%s 后重试: %v\n", item.Source, delay, err)
+		select {
+		case <-ctx.Done():
+			return StageFailed, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// ingestFile 提取、切片、Embedding 并写入单个文件，成功后更新清单。
+// 写入前总是先删除该 source 已有的点：既清掉变更前的旧切片，也清掉清单出现之前用随机 ID 导入的点。
+func (in *Ingester) ingestFile(ctx context.Context, item PlanItem, fp *FileProgress, report func(Stage)) error {
+	file, source := item.Path, item.Source
+
+	// 1. 提取 + 切片
+	report(StageExtracting)
+	segs, err := extractSegments(file)
+	if err != nil {
+		return permanentError{fmt.Errorf("提取文本失败: %w", err)}
+	}
+	meta := item.meta
+	if meta.Language == "" {
+		var sb strings.Builder
+		for _, seg := range segs {
+			sb.WriteString(seg.Text)
+		}
+		meta.Language = detectLanguage(sb.String())
+	}
+
+	// 按分段切片，片段不跨页 / 跨节，才能准确记录其位置
+	var (
+		chunks []string
+		segOf  []*extractor.Segment
+	)
+	for i := range segs {
+		for _, c := range in.chunker.Chunk(segs[i].Text) {
+			chunks = append(chunks, c)
+			segOf = append(segOf, &segs[i])
+		}
+	}
+	log.Printf("文件 %s 切成 %d 段\n", source, len(chunks))
+	fp.Chunks, fp.Embedded = len(chunks), 0
+
+	// 2. 按 EmbedBatchSize 分批 Embedding + 构造 Point
+	report(StageEmbedding)
+	batch := in.cfg.EmbedBatchSize
+	if batch <= 0 {
+		batch = 1
+	}
+	points := make([]store.Point, 0, len(chunks))
+	for start := 0; start < len(chunks); start += batch {
+		end := start + batch
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		vecs, err := in.embed(ctx, chunks[start:end])
+		if err != nil {
+			return fmt.Errorf("生成 Embedding 失败 (段 %d-%d): %w", start, end-1, err)
+		}
+		for i, vec := range vecs {
+			idx := start + i
+			points = append(points, store.Point{
+				ID:      pointID(source, idx, chunks[idx]),
+				Vector:  vec,
+				Payload: chunkPayload(source, idx, chunks[idx], segOf[idx], meta, in.model),
+			})
+		}
+		fp.Embedded = end
+		report(StageEmbedding)
+	}
+
+	// 3. 删除旧点后分批 Upsert
+	report(StageUpserting)
+	if err := in.db.Delete(ctx, store.SourceFilter(source)); err != nil {
+		return fmt.Errorf("删除旧向量失败: %w", err)
+	}
+	if len(points) > 0 {
+		if err := in.db.Upsert(ctx, points); err != nil {
+			return fmt.Errorf("写入向量库失败: %w", err)
+		}
+	}
+	docs := make([]retrieval.Doc, len(points))
+	for i, pt := range points {
+		docs[i] = retrieval.Doc{ID: pt.ID, Payload: pt.Payload}
+	}
+	if err := in.keywords.Put(source, docs); err != nil {
+		return fmt.Errorf("更新关键词索引失败: %w", err)
+	}
+
+	st := item.state
+	st.Chunks, st.Chunker, st.Meta, st.IngestedAt = len(points), chunkerFingerprint(in.cfg), item.meta.fingerprint(), time.Now()
+	st.EmbedModel = in.model
+	if err := in.manifest.Put(source, st); err != nil {
+		return permanentError{fmt.Errorf("保存导入清单失败: %w", err)}
+	}
+	return nil
+}
+
+// chunkPayload 片段写入向量库的 payload；位置字段只在提取器给出时才写入。
+// embed_model 记录生成向量的模型，启动时据此发现换了模型却没重建的集合
+func chunkPayload(source string, idx int, text string, seg *extractor.Segment, meta Metadata, model string) map[string]interface{} {
+	payload := map[string]interface{}{
+		"text":        text,
+		"source":      source,
+		"index":       idx,
+		"embed_model": model,
+	}
+	meta.apply(payload, fileType(source))
+	if seg.Page > 0 {
+		payload["page"] = seg.Page
+	}
+	if seg.Slide > 0 {
+		payload["slide"] = seg.Slide
+	}
+	if len(seg.Headings) > 0 {
+		payload["headings"] = seg.Headings
+		payload["section"] = strings.Join(seg.Headings, " > ")
+	}
+	if seg.Style != "" {
+		payload["style"] = seg.Style
+	}
+	return payload
+}
+
+// embed 在 embedSem 限流下批量生成向量：提取是本地 CPU 工作可以多个 worker 并行，
+// 而 Embedding 受限于 Ollama 的并发能力，单独限流才能让两者流水线重叠
+func (in *Ingester) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	select {
+	case in.embedSem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-in.embedSem }()
+	return in.embedder.EmbedBatch(ctx, texts)
+}
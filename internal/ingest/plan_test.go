@@ -0,0 +1,97 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/retrieval"
+)
+
+// 清单中记录的切片参数、Embedding 模型或内容与当前不一致时重导，否则跳过
+func TestPlanFile(t *testing.T) {
+	dir := t.TempDir()
+	kdir := filepath.Join(dir, "knowledge")
+	if err := os.MkdirAll(filepath.Join(kdir, "optics"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := File{Path: filepath.Join(kdir, "optics", "grating.md"), Source: "optics/grating.md"}
+	if err := os.WriteFile(file.Path, []byte("光栅方程 d sinθ = kλ"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := config.Config{KnowledgeDir: kdir, ChunkSize: 500, ChunkOverlap: 50}
+	meta, err := loadMetadata(kdir, file.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := FileState{Hash: hash, Size: info.Size(), ModTime: info.ModTime(), Chunks: 1,
+		Chunker: chunkerFingerprint(&base), Meta: meta.fingerprint(), EmbedModel: "bge-m3"}
+
+	tests := []struct {
+		name   string
+		state  *FileState // nil 表示清单中没有
+		cfg    func(*config.Config)
+		model  string
+		noKW   bool // 关键词索引中没有该文件
+		force  bool
+		action Action
+	}{
+		{"新文件", nil, nil, "bge-m3", false, false, ActionAdd},
+		{"未变化", &imported, nil, "bge-m3", false, false, ActionSkip},
+		{"强制重导", &imported, nil, "bge-m3", false, true, ActionUpdate},
+		{"切片大小变了", &imported, func(c *config.Config) { c.ChunkSize = 300 }, "bge-m3", false, false, ActionUpdate},
+		{"切片策略变了", &imported, func(c *config.Config) { c.ChunkStrategy = ChunkSemantic }, "bge-m3", false, false, ActionUpdate},
+		{"Embedding 模型变了", &imported, nil, "nomic-embed-text", false, false, ActionUpdate},
+		{"关键词索引缺失", &imported, nil, "bge-m3", true, false, ActionUpdate},
+		{"内容变了", &FileState{Hash: "old", Size: 1, ModTime: info.ModTime().Add(-1), Chunks: 1,
+			Chunker: imported.Chunker, Meta: imported.Meta, EmbedModel: "bge-m3"}, nil, "bge-m3", false, false, ActionUpdate},
+		{"只是 touch 过", &FileState{Hash: hash, Size: info.Size(), ModTime: info.ModTime().Add(-1), Chunks: 1,
+			Chunker: imported.Chunker, Meta: imported.Meta, EmbedModel: "bge-m3"}, nil, "bge-m3", false, false, ActionSkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := t.TempDir()
+			cfg := base
+			if tt.cfg != nil {
+				tt.cfg(&cfg)
+			}
+			manifest, err := LoadManifest(filepath.Join(sub, "manifest.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			keywords, err := retrieval.LoadIndex(filepath.Join(sub, "kw.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.state != nil {
+				if err := manifest.Put(file.Source, *tt.state); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !tt.noKW {
+				if err := keywords.Put(file.Source, []retrieval.Doc{{ID: "1", Payload: map[string]interface{}{"text": "光栅"}}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			in := &Ingester{cfg: &cfg, model: tt.model, manifest: manifest, keywords: keywords}
+			item, err := in.planFile(file, tt.force)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.Action != tt.action {
+				t.Errorf("Action = %s, want %s", item.Action, tt.action)
+			}
+		})
+	}
+}
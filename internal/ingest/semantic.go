@@ -0,0 +1,315 @@
+package ingest
+
+import (
+	"regexp"
+	"strings"
+)
+
+/*
+semanticChunker 面向物理实验指导书的结构化切片
+
+先把文本切成"块"：公式（$$…$$、\[…\]、\begin{equation}…、带编号的等式行）、
+编号步骤列表（1. / 1、/ (1) / ① / 步骤1 / Step 1）、表格（| 分隔、制表符或多空格对齐的多列行）、
+图表标题（图 1-2 / 表 3 / Fig. 4）以及普通段落。公式、表格、步骤列表不会在内部被切开；
+图注与其所说明的表格或前一块粘在一起。然后把相邻的小块聚合到 CHUNK_SIZE 以内。
+
+超长块的处理：表格按行分组并重复表头，步骤列表按条目分组，普通段落按句（不切断行内公式）聚合，
+公式块保持完整，即使超过上限。
+*/
+type semanticChunker struct{ sentenceChunker }
+
+type blockKind int
+
+const (
+	blockText blockKind = iota
+	blockMath
+	blockTable
+	blockSteps
+	blockCaption
+)
+
+type block struct {
+	kind  blockKind
+	lines []string
+}
+
+func (b block) text() string { return strings.Join(b.lines, "\n") }
+
+var (
+	// 步骤条目开头：1. / 1、/ 1) / (1) / （1）/ ①…⑳ / 步骤1 / 第1步 / Step 1；
+	// "1." 与 "1)" 后必须有空白，"2.5 kg"、"9.8 m/s²" 这类以小数开头的行不算步骤
+	stepRe = regexp.MustCompile(`^(\d{1,2}(?:[.)]\s+|[、）]\s*)\S|[(（]\d{1,2}[)）]|[①-⑳]|步骤\s*\d|第\s*[\d一二三四五六七八九十]+\s*步|(?i:step)\s*\d)`)
+	// 图表标题：图 1 / 图1-2 / 表 3.1 / Fig. 4 / Figure 2 / Table 1
+	captionRe = regexp.MustCompile(`^(图|表)\s*\d+([-.．]\d+)*|^(?i:fig\.?|figure|table)\s*\d+`)
+	// 表格行：至少两个 | ，或至少三列由制表符 / 两个以上空格分隔
+	pipeRowRe  = regexp.MustCompile(`\|.*\|`)
+	alignColRe = regexp.MustCompile(`\t+| {2,}`)
+	// 独立成行、末尾带编号的等式，如 "R = U / I    (3)"
+	eqLineRe = regexp.MustCompile(`=.*[(（]\d+([-.]\d+)?[)）]\s*$`)
+	// 不能在其中断句的行内公式
+	inlineMathRe = regexp.MustCompile(`\$[^$\n]+\$|\\\([^\n]*?\\\)`)
+
+	mathEnvBeginRe = regexp.MustCompile(`^\\begin\{(equation|align|gather|multline|eqnarray)\*?\}`)
+)
+
+func (c semanticChunker) Chunk(text string) []string {
+	blocks := mergeCaptions(parseBlocks(text))
+
+	var units []string
+	for _, b := range blocks {
+		if t := b.text(); c.measure(t) <= c.size {
+			units = append(units, t)
+			continue
+		}
+		units = append(units, c.splitBlock(b)...)
+	}
+	// 超长单元已在 splitBlock 中按结构拆好，剩下的（整块公式）保持原样
+	return c.pack(units, "\n\n", func(u string) []string { return []string{u} })
+}
+
+// splitBlock 把超过上限的块按其结构拆成若干单元
+func (c semanticChunker) splitBlock(b block) []string {
+	switch b.kind {
+	case blockMath:
+		return []string{b.text()}
+	case blockTable:
+		header := tableHeader(b.lines)
+		return c.groupLines(header, b.lines[len(header):])
+	case blockSteps:
+		return c.groupItems(stepItems(b.lines))
+	case blockCaption:
+		// 表标题 + 表格：每组都带上标题和表头
+		if len(b.lines) > 1 && isTableRow(strings.TrimSpace(b.lines[1])) {
+			header := tableHeader(b.lines[1:])
+			return c.groupLines(append([]string{b.lines[0]}, header...), b.lines[1+len(header):])
+		}
+	}
+	return c.pack(protectedSentences(b.text()), "", c.hardSplit)
+}
+
+// groupLines 把表格数据行分组到上限以内，每组开头重复 header
+func (c semanticChunker) groupLines(header, rows []string) []string {
+	var out []string
+	cur := append([]string(nil), header...)
+	for _, l := range rows {
+		if len(cur) > len(header) && c.measure(strings.Join(append(cur, l), "\n")) > c.size {
+			out = append(out, strings.Join(cur, "\n"))
+			cur = append([]string(nil), header...)
+		}
+		cur = append(cur, l)
+	}
+	if len(cur) > len(header) {
+		out = append(out, strings.Join(cur, "\n"))
+	}
+	return out
+}
+
+// groupItems 把步骤条目聚合到上限以内，单个条目不拆开
+func (c semanticChunker) groupItems(items []string) []string {
+	return c.pack(items, "\n", func(u string) []string { return []string{u} })
+}
+
+// parseBlocks 逐行扫描，识别各类块
+func parseBlocks(text string) []block {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var blocks []block
+	var para []string
+	flushPara := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, block{kind: blockText, lines: para})
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flushPara()
+
+		case strings.HasPrefix(trimmed, "$$") || strings.HasPrefix(trimmed, `\[`) || mathEnvBeginRe.MatchString(trimmed):
+			flushPara()
+			end := mathBlockEnd(lines, i)
+			blocks = append(blocks, block{kind: blockMath, lines: lines[i : end+1]})
+			i = end
+
+		case eqLineRe.MatchString(trimmed) && len([]rune(trimmed)) <= 120:
+			// 编号等式紧跟在说明它的段落之后，作为独立公式块
+			flushPara()
+			blocks = append(blocks, block{kind: blockMath, lines: []string{line}})
+
+		case captionRe.MatchString(trimmed) && len([]rune(trimmed)) <= 80:
+			flushPara()
+			blocks = append(blocks, block{kind: blockCaption, lines: []string{line}})
+
+		case isTableRow(trimmed) && i+1 < len(lines) && isTableRow(strings.TrimSpace(lines[i+1])):
+			flushPara()
+			j := i
+			for j < len(lines) && isTableRow(strings.TrimSpace(lines[j])) {
+				j++
+			}
+			blocks = append(blocks, block{kind: blockTable, lines: lines[i:j]})
+			i = j - 1
+
+		case stepRe.MatchString(trimmed):
+			flushPara()
+			j := i + 1
+			// 步骤列表一直延续到空行后不再出现新条目为止；条目内的续行归入该条目
+			for j < len(lines) {
+				t := strings.TrimSpace(lines[j])
+				if t == "" {
+					k := j + 1
+					for k < len(lines) && strings.TrimSpace(lines[k]) == "" {
+						k++
+					}
+					if k < len(lines) && stepRe.MatchString(strings.TrimSpace(lines[k])) {
+						j = k
+						continue
+					}
+					break
+				}
+				if captionRe.MatchString(t) || (isTableRow(t) && !stepRe.MatchString(t)) {
+					break
+				}
+				j++
+			}
+			blocks = append(blocks, block{kind: blockSteps, lines: lines[i:j]})
+			i = j - 1
+
+		default:
+			para = append(para, line)
+		}
+	}
+	flushPara()
+	return blocks
+}
+
+// mathBlockEnd 找到从 start 开始的公式块的最后一行
+func mathBlockEnd(lines []string, start int) int {
+	first := strings.TrimSpace(lines[start])
+	var closer string
+	switch {
+	case strings.HasPrefix(first, "$$"):
+		if strings.Count(first, "$$") >= 2 {
+			return start // $$ … $$ 在同一行
+		}
+		closer = "$$"
+	case strings.HasPrefix(first, `\[`):
+		if strings.Contains(first[2:], `\]`) {
+			return start
+		}
+		closer = `\]`
+	default:
+		env := mathEnvBeginRe.FindStringSubmatch(first)[0]
+		closer = strings.Replace(env, `\begin`, `\end`, 1)
+		if strings.Contains(first, closer) {
+			return start
+		}
+	}
+	for i := start + 1; i < len(lines); i++ {
+		if strings.Contains(lines[i], closer) {
+			return i
+		}
+	}
+	return len(lines) - 1 // 未闭合：直到文末
+}
+
+// mergeCaptions 让图表标题与其说明对象粘在一起：表标题并入后面的表格，
+// 其余标题（图注）并入前一块；前后都没有可并的块时保持独立
+func mergeCaptions(blocks []block) []block {
+	var out []block
+	for i := 0; i < len(blocks); i++ {
+		b := blocks[i]
+		if b.kind != blockCaption {
+			out = append(out, b)
+			continue
+		}
+		if i+1 < len(blocks) && blocks[i+1].kind == blockTable {
+			merged := block{kind: blockCaption, lines: append(append([]string(nil), b.lines...), blocks[i+1].lines...)}
+			out = append(out, merged)
+			i++
+			continue
+		}
+		if n := len(out); n > 0 {
+			prev := out[n-1]
+			out[n-1] = block{kind: prev.kind, lines: append(append([]string(nil), prev.lines...), b.lines...)}
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func isTableRow(line string) bool {
+	if line == "" {
+		return false
+	}
+	if pipeRowRe.MatchString(line) {
+		return true
+	}
+	return len(alignColRe.Split(line, -1)) >= 3
+}
+
+// tableHeader Markdown 表格返回表头与分隔行，其余表格返回第一行
+func tableHeader(lines []string) []string {
+	if len(lines) >= 2 && strings.Contains(lines[1], "---") {
+		return lines[:2]
+	}
+	if len(lines) > 0 {
+		return lines[:1]
+	}
+	return nil
+}
+
+// stepItems 把步骤列表拆成条目，续行归入前一条目
+func stepItems(lines []string) []string {
+	var items []string
+	for _, l := range lines {
+		t := strings.TrimSpace(l)
+		if t == "" {
+			continue
+		}
+		if stepRe.MatchString(t) || len(items) == 0 {
+			items = append(items, l)
+			continue
+		}
+		items[len(items)-1] += "\n" + l
+	}
+	return items
+}
+
+// protectedSentences 切句，但不会在行内公式 $…$ / \(…\) 内部断开
+func protectedSentences(text string) []string {
+	spans := inlineMathRe.FindAllStringIndex(text, -1)
+	if len(spans) == 0 {
+		return splitSentences(text)
+	}
+
+	// 用占位符替换公式后切句，再把公式还原
+	var sb strings.Builder
+	var formulas []string
+	last := 0
+	for _, sp := range spans {
+		sb.WriteString(text[last:sp[0]])
+		sb.WriteString(placeholder(len(formulas)))
+		formulas = append(formulas, text[sp[0]:sp[1]])
+		last = sp[1]
+	}
+	sb.WriteString(text[last:])
+
+	sentences := splitSentences(sb.String())
+	for i, s := range sentences {
+		for k, f := range formulas {
+			s = strings.Replace(s, placeholder(k), f, 1)
+		}
+		sentences[i] = s
+	}
+	return sentences
+}
+
+// placeholder 私有区字符包裹的编号，不含任何断句标点
+func placeholder(i int) string {
+	return "\uE000" + strings.Repeat("\uE001", i+1) + "\uE000"
+}
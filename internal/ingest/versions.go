@@ -0,0 +1,348 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// 版本目录中的文件：该版本自己的导入清单、关键词索引与构建信息
+const (
+	versionManifest = "manifest.json"
+	versionKeywords = "keywords.json"
+	versionInfoFile = "version.json"
+)
+
+// VersionInfo 构建完成时写入版本目录的信息
+type VersionInfo struct {
+	EmbedModel string    `json:"embed_model"`
+	Dim        int       `json:"dim"`
+	Chunker    string    `json:"chunker"`
+	Files      int       `json:"files"`
+	Chunks     int       `json:"chunks"`
+	BuiltAt    time.Time `json:"built_at"`
+}
+
+// Version 向量库中的一个版本集合
+type Version struct {
+	VersionInfo
+	Name   string // 集合名，如 physics_v3
+	Number int
+	Active bool // 别名当前指向它
+	Points int  // 当前点数，获取失败时为 -1
+}
+
+// versionDir 版本目录 {INGEST_MANIFEST 所在目录}/versions/{集合名}
+func versionDir(cfg *config.Config, name string) string {
+	return filepath.Join(filepath.Dir(cfg.IngestManifest), "versions", name)
+}
+
+/*
+Rebuild 蓝绿重建：把知识库全部导入新的版本集合 {QDRANT_COLLECTION}_vN，成功后切换别名
+
+重建期间线上检索仍通过别名读旧版本；新版本使用版本目录中独立的清单与关键词索引，
+切换时才替换线上文件。换切片参数或 Embedding 模型都应走这里，而不是就地 reindex。
+有文件导入失败时不切换，新集合保留，可修复后重试或用 GC 清理。返回新版本的集合名。
+QDRANT_COLLECTION 还是普通集合时，dropLegacy 为 false 会在构建前就报错，见 Activate。
+*/
+func Rebuild(ctx context.Context, cfg *config.Config, progress ProgressFunc, dropLegacy bool) (string, Report, error) {
+	vs, err := store.NewVersions(cfg)
+	if err != nil {
+		return "", Report{}, err
+	}
+	if !dropLegacy {
+		legacy, err := vs.Legacy(ctx)
+		if err != nil {
+			return "", Report{}, err
+		}
+		if legacy {
+			return "", Report{}, legacyError(cfg)
+		}
+	}
+	dim, err := probeEmbedding(ctx, cfg)
+	if err != nil {
+		return "", Report{}, err
+	}
+	names, err := vs.List(ctx)
+	if err != nil {
+		return "", Report{}, err
+	}
+	next := 1
+	if len(names) > 0 {
+		last, _ := store.VersionNumber(cfg.QdrantCol, names[len(names)-1])
+		next = last + 1
+	}
+	name := store.VersionName(cfg.QdrantCol, next)
+
+	dir := versionDir(cfg, name)
+	if err := os.RemoveAll(dir); err != nil {
+		return name, Report{}, err
+	}
+	vcfg := *cfg
+	vcfg.QdrantCol = name
+	vcfg.IngestManifest = filepath.Join(dir, versionManifest)
+	vcfg.KeywordIndex = filepath.Join(dir, versionKeywords)
+	in, err := New(&vcfg)
+	if err != nil {
+		return name, Report{}, err
+	}
+	if err := in.db.EnsureCollection(dim); err != nil {
+		return name, Report{}, err
+	}
+
+	log.Printf("开始构建版本 %s（Embedding %s，切片 %s）\n", name, in.model, chunkerFingerprint(cfg))
+	plan, err := in.Plan()
+	if err != nil {
+		return name, Report{}, err
+	}
+	rep, err := in.Execute(ctx, plan, progress)
+	if err != nil {
+		return name, rep, err
+	}
+	if rep.Failed > 0 {
+		return name, rep, fmt.Errorf("%d 个文件导入失败，未切换到 %s；可修复后重新 rebuild，或执行 gc 清理", rep.Failed, name)
+	}
+
+	info := VersionInfo{EmbedModel: in.model, Dim: dim, Chunker: chunkerFingerprint(cfg), BuiltAt: time.Now()}
+	for _, st := range in.manifest.Snapshot() {
+		info.Files++
+		info.Chunks += st.Chunks
+	}
+	if err := writeJSON(filepath.Join(dir, versionInfoFile), info); err != nil {
+		return name, rep, err
+	}
+	return name, rep, Activate(ctx, cfg, name, dropLegacy)
+}
+
+/*
+Activate 把别名切到版本 name，并换上该版本的导入清单与关键词索引
+
+切换前先把线上清单与关键词索引存回当前版本的目录：切换后的增量同步可能改过它们，回滚时才能原样恢复。
+API 进程按文件修改时间自动重新加载清单与关键词索引，无需重启。
+QDRANT_COLLECTION 还是启用版本管理前的普通集合时，只有 dropLegacy 为 true 才删除它并切换，否则报错。
+*/
+func Activate(ctx context.Context, cfg *config.Config, name string, dropLegacy bool) error {
+	vs, err := store.NewVersions(cfg)
+	if err != nil {
+		return err
+	}
+	names, err := vs.List(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(names, name) {
+		return fmt.Errorf("版本 %s 不存在", name)
+	}
+	dir := versionDir(cfg, name)
+	if _, err := os.Stat(filepath.Join(dir, versionManifest)); err != nil {
+		log.Printf("版本 %s 没有导入清单，切换后下次 sync 会把全部文件重新导入该版本\n", name)
+	}
+
+	active, err := vs.Active(ctx)
+	if err != nil {
+		return err
+	}
+	if active == name {
+		log.Printf("别名 %s 已指向 %s\n", cfg.QdrantCol, name)
+		return nil
+	}
+	if active != "" {
+		prev := versionDir(cfg, active)
+		if err := copyJSON(cfg.IngestManifest, filepath.Join(prev, versionManifest)); err != nil {
+			return fmt.Errorf("保存 %s 的导入清单失败: %w", active, err)
+		}
+		if err := copyJSON(cfg.KeywordIndex, filepath.Join(prev, versionKeywords)); err != nil {
+			return fmt.Errorf("保存 %s 的关键词索引失败: %w", active, err)
+		}
+	} else if dropLegacy {
+		legacy, err := vs.Legacy(ctx)
+		if err != nil {
+			return err
+		}
+		if legacy {
+			log.Printf("删除启用版本管理前创建的普通集合 %s，之后无法回滚到它\n", cfg.QdrantCol)
+		}
+	}
+
+	if err := vs.Switch(ctx, name, dropLegacy); err != nil {
+		if errors.Is(err, store.ErrLegacyCollection) {
+			return legacyError(cfg)
+		}
+		return err
+	}
+	if err := copyJSON(filepath.Join(dir, versionManifest), cfg.IngestManifest); err != nil {
+		return fmt.Errorf("替换导入清单失败: %w", err)
+	}
+	if err := copyJSON(filepath.Join(dir, versionKeywords), cfg.KeywordIndex); err != nil {
+		return fmt.Errorf("替换关键词索引失败: %w", err)
+	}
+	if active != "" {
+		log.Printf("别名 %s 已从 %s 切换到 %s\n", cfg.QdrantCol, active, name)
+	} else {
+		log.Printf("别名 %s 已指向 %s\n", cfg.QdrantCol, name)
+	}
+	return nil
+}
+
+// Rollback 把别名切回 target 指定的版本（集合名、"v2" 或 "2"）；target 为空时切回当前版本之前最近的一个
+func Rollback(ctx context.Context, cfg *config.Config, target string) (string, error) {
+	vs, err := store.NewVersions(cfg)
+	if err != nil {
+		return "", err
+	}
+	if target != "" {
+		name, err := versionArg(cfg.QdrantCol, target)
+		if err != nil {
+			return "", err
+		}
+		return name, Activate(ctx, cfg, name, false)
+	}
+
+	names, err := vs.List(ctx)
+	if err != nil {
+		return "", err
+	}
+	active, err := vs.Active(ctx)
+	if err != nil {
+		return "", err
+	}
+	cur, ok := store.VersionNumber(cfg.QdrantCol, active)
+	if !ok {
+		return "", fmt.Errorf("别名 %s 还没有指向任何版本", cfg.QdrantCol)
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		if n, _ := store.VersionNumber(cfg.QdrantCol, names[i]); n < cur {
+			return names[i], Activate(ctx, cfg, names[i], false)
+		}
+	}
+	return "", fmt.Errorf("%s 之前没有可以回滚的版本", active)
+}
+
+// GC 删除旧版本的集合与版本目录，保留当前版本以及其余版本中最新的 keep 个（便于回滚），返回被删除的集合名
+func GC(ctx context.Context, cfg *config.Config, keep int) ([]string, error) {
+	vs, err := store.NewVersions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	names, err := vs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	active, err := vs.Active(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for i := len(names) - 1; i >= 0; i-- {
+		if names[i] == active {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		if err := vs.Drop(ctx, names[i]); err != nil {
+			return dropped, err
+		}
+		if err := os.RemoveAll(versionDir(cfg, names[i])); err != nil {
+			return dropped, err
+		}
+		dropped = append(dropped, names[i])
+	}
+	return dropped, nil
+}
+
+// ListVersions 列出全部版本及其构建信息、点数，按版本号升序
+func ListVersions(ctx context.Context, cfg *config.Config) ([]Version, error) {
+	vs, err := store.NewVersions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	names, err := vs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	active, err := vs.Active(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Version, 0, len(names))
+	for _, name := range names {
+		v := Version{Name: name, Active: name == active, Points: -1}
+		v.Number, _ = store.VersionNumber(cfg.QdrantCol, name)
+		if b, err := os.ReadFile(filepath.Join(versionDir(cfg, name), versionInfoFile)); err == nil {
+			_ = json.Unmarshal(b, &v.VersionInfo)
+		}
+		vcfg := *cfg
+		vcfg.QdrantCol = name
+		if db, err := store.New(&vcfg); err == nil {
+			if n, err := db.Count(ctx); err == nil {
+				v.Points = n
+			}
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// legacyError 提示运维人员先处理同名的普通集合
+func legacyError(cfg *config.Config) error {
+	return fmt.Errorf("%w %s（启用版本管理前创建），切换别名前必须删除它，之后无法回滚到它；"+
+		"确认不再需要后加 -drop-legacy 重新执行 rebuild，或先把数据迁移走", store.ErrLegacyCollection, cfg.QdrantCol)
+}
+
+// versionArg 把 "physics_v2"、"v2"、"2" 统一为集合名
+func versionArg(alias, s string) (string, error) {
+	if _, ok := store.VersionNumber(alias, s); ok {
+		return s, nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "v"))
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("无法识别的版本: %s", s)
+	}
+	return store.VersionName(alias, n), nil
+}
+
+// copyJSON 把 src 复制为 dst（写临时文件后 rename，其它进程据修改时间重新加载）；
+// src 不存在时写入空对象，清单与关键词索引都把它当作空
+func copyJSON(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if errors.Is(err, os.ErrNotExist) {
+		b = []byte("{}")
+	} else if err != nil {
+		return err
+	}
+	return writeFile(dst, b)
+}
+
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, b)
+}
+
+func writeFile(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,148 @@
+package ingest
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WalkOptions 控制知识库目录的遍历方式
+type WalkOptions struct {
+	Include        []string // 只导入匹配的文件；为空表示全部
+	Exclude        []string // 匹配的文件或目录一律跳过，优先于 Include
+	FollowSymlinks bool     // 跟随符号链接（带环检测）；否则跳过所有链接
+	IncludeHidden  bool     // 是否包含以 . 开头的文件和目录
+}
+
+// File 待导入的知识文件
+type File struct {
+	Path   string // 磁盘路径
+	Source string // 相对知识库根目录的路径，统一使用 /，作为 payload.source
+}
+
+// Walk 递归遍历 root，按 WalkOptions 过滤后返回按 Source 排序的文件列表，
+// 以及因格式不支持（见 Supported）而跳过的文件的 Source。
+//
+// 模式语法同 path.Match，额外支持 ** 匹配任意层目录：
+// 不含 / 的模式只匹配文件名（如 "*.pdf"），含 / 的模式匹配相对路径（如 "optics/**"、"**/draft/*"）。
+func Walk(root string, opt WalkOptions) ([]File, []string, error) {
+	w := &walker{opt: opt, visited: map[string]bool{}}
+	if err := w.walkDir(root, ""); err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(w.files, func(i, j int) bool { return w.files[i].Source < w.files[j].Source })
+	sort.Strings(w.unsupported)
+	return w.files, w.unsupported, nil
+}
+
+type walker struct {
+	opt         WalkOptions
+	visited     map[string]bool // 已进入目录的真实路径，防止符号链接成环
+	files       []File
+	unsupported []string
+}
+
+func (w *walker) walkDir(dir, rel string) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("解析目录 %s 失败: %w", dir, err)
+	}
+	if w.visited[real] {
+		log.Printf("跳过 %s: 符号链接成环\n", dir)
+		return nil
+	}
+	w.visited[real] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("扫描知识库目录失败: %w", err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !w.opt.IncludeHidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		p := filepath.Join(dir, name)
+		r := path.Join(rel, name)
+
+		isDir := e.IsDir()
+		if e.Type()&os.ModeSymlink != 0 {
+			if !w.opt.FollowSymlinks {
+				continue
+			}
+			info, err := os.Stat(p)
+			if err != nil {
+				log.Printf("跳过 %s: %v\n", p, err)
+				continue
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				continue
+			}
+			isDir = info.IsDir()
+		} else if !isDir && !e.Type().IsRegular() {
+			continue // 设备文件、管道等
+		}
+
+		if matchAny(w.opt.Exclude, r) {
+			continue
+		}
+		if isDir {
+			if err := w.walkDir(p, r); err != nil {
+				return err
+			}
+			continue
+		}
+		if IsSidecar(name) || (len(w.opt.Include) > 0 && !matchAny(w.opt.Include, r)) {
+			continue
+		}
+		if !Supported(name) {
+			w.unsupported = append(w.unsupported, r)
+			continue
+		}
+		w.files = append(w.files, File{Path: p, Source: r})
+	}
+	return nil
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob 见 Walk 的模式说明；非法模式视为不匹配
+func matchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
@@ -0,0 +1,58 @@
+package llm
+
+import "context"
+
+// Message 一条对话消息，Role 为 system / user / assistant
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Reply 模型的一次完整回答；推理模型（如 deepseek-r1）的思考过程单独放在 Reasoning，不混入 Content
+type Reply struct {
+	Content   string
+	Reasoning string
+}
+
+// Delta 流式输出中的一段增量，Content 与 Reasoning 通常只有一个非空
+type Delta struct {
+	Content   string
+	Reasoning string
+}
+
+// StreamFunc 每收到一段增量内容时回调；返回错误会中止读取
+type StreamFunc func(delta Delta) error
+
+// ChatModel 生成回答的聊天模型；实现见 internal/ollama 与 internal/llm/openai，按 LLM_PROVIDER 选择（internal/llm/provider）
+type ChatModel interface {
+	// Chat 以完整 messages（可含多轮历史）请求一次回答
+	Chat(ctx context.Context, msgs []Message) (Reply, error)
+	// ChatStream 流式请求回答，每段增量回调 fn，结束后返回完整回答；ctx 取消时立即中断
+	ChatStream(ctx context.Context, msgs []Message, fn StreamFunc) (Reply, error)
+	// Generate 不带对话格式的单轮补全，只返回去掉推理过程后的内容，model 为空时使用默认聊天模型；
+	// options 采用 Ollama 的命名（temperature、num_predict 等），其它实现自行换算
+	Generate(ctx context.Context, model, prompt string, options map[string]interface{}) (string, error)
+}
+
+// Embedder 把文本转成向量，按 EMBED_PROVIDER 选择实现
+type Embedder interface {
+	// Embeddings 为单段文本生成向量，用于检索时的问题
+	Embeddings(ctx context.Context, text string) ([]float32, error)
+	// EmbedBatch 一次为多段文本生成向量，返回顺序与 texts 一致，用于导入
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Messages 把 system + 用户问题封装成 messages，system 为空时不发送 system 消息
+func Messages(prompt, system string) []Message {
+	var msgs []Message
+	if system != "" {
+		msgs = append(msgs, Message{Role: "system", Content: system})
+	}
+	return append(msgs, Message{Role: "user", Content: prompt})
+}
+
+// Complete 单轮提问，只返回回答内容，丢弃推理过程
+func Complete(ctx context.Context, m ChatModel, prompt, system string) (string, error) {
+	reply, err := m.Chat(ctx, Messages(prompt, system))
+	return reply.Content, err
+}
@@ -0,0 +1,298 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/llm"
+)
+
+/*
+Client 调用 OpenAI 兼容的 HTTP API（/chat/completions、/completions、/embeddings），
+llama.cpp server、vLLM、LM Studio 等本地推理服务都提供这套接口。
+
+BaseURL 需包含版本前缀，如 http://localhost:8000/v1；本地服务通常不校验 API key，留空即可。
+*/
+type Client struct {
+	cli        *resty.Client
+	stream     *resty.Client // 流式请求专用，不设整体超时，靠 ctx 取消
+	model      string
+	embedModel string
+}
+
+var (
+	_ llm.ChatModel = (*Client)(nil)
+	_ llm.Embedder  = (*Client)(nil)
+)
+
+// NewClient 按 OPENAI_* 配置创建客户端
+func NewClient(cfg *config.Config) *Client {
+	newResty := func() *resty.Client {
+		c := resty.New().
+			SetBaseURL(strings.TrimRight(cfg.OpenAIURL, "/")).
+			SetHeader("Content-Type", "application/json")
+		if cfg.OpenAIKey != "" {
+			c.SetAuthToken(cfg.OpenAIKey)
+		}
+		return c
+	}
+	return &Client{
+		cli:        newResty().SetTimeout(60 * time.Second),
+		stream:     newResty(),
+		model:      cfg.OpenAIModel,
+		embedModel: cfg.OpenAIEmbedModel,
+	}
+}
+
+// apiError OpenAI 风格的错误响应体
+type apiError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// message 响应中的 message / delta；vLLM、DeepSeek 等把推理过程放在 reasoning_content，
+// 也有服务用 reasoning 字段
+type message struct {
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content"`
+	Reasoning        string `json:"reasoning"`
+}
+
+func (m message) reasoning() string {
+	return m.ReasoningContent + m.Reasoning
+}
+
+// errorf 带上响应体里的错误信息，便于排查模型名写错等问题
+func errorf(op string, r *resty.Response) error {
+	var e apiError
+	if json.Unmarshal(r.Body(), &e) == nil && e.Error.Message != "" {
+		return fmt.Errorf("openai %s error: %s — %s", op, r.Status(), e.Error.Message)
+	}
+	return fmt.Errorf("openai %s error: %s", op, r.Status())
+}
+
+// Chat 调用 /chat/completions，返回第一个 choice 的回复；推理过程取自 reasoning_content 或内联的 <think> 标签
+func (c *Client) Chat(ctx context.Context, msgs []llm.Message) (llm.Reply, error) {
+	reqBody := map[string]interface{}{
+		"model":    c.model,
+		"messages": msgs,
+		"stream":   false,
+	}
+
+	var resp struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	r, err := c.cli.R().
+		SetContext(ctx).
+		SetBody(reqBody).
+		SetResult(&resp).
+		Post("/chat/completions")
+	if err != nil {
+		return llm.Reply{}, err
+	}
+	if r.IsError() {
+		return llm.Reply{}, errorf("chat", r)
+	}
+	if len(resp.Choices) == 0 {
+		return llm.Reply{}, fmt.Errorf("openai chat 返回空的 choices")
+	}
+	msg := resp.Choices[0].Message
+	reply := llm.SplitThink(msg.Content)
+	reply.Reasoning = msg.reasoning() + reply.Reasoning
+	return reply, nil
+}
+
+/*
+ChatStream 以 "stream": true 调用 /chat/completions
+
+服务端按 SSE 返回，每行 "data: {json}" 携带一段 choices[0].delta.content（推理过程在 reasoning_content），
+以 "data: [DONE]" 结束。
+*/
+func (c *Client) ChatStream(ctx context.Context, msgs []llm.Message, fn llm.StreamFunc) (llm.Reply, error) {
+	reqBody := map[string]interface{}{
+		"model":    c.model,
+		"messages": msgs,
+		"stream":   true,
+	}
+
+	r, err := c.stream.R().
+		SetContext(ctx).
+		SetBody(reqBody).
+		SetDoNotParseResponse(true).
+		Post("/chat/completions")
+	if err != nil {
+		return llm.Reply{}, err
+	}
+	body := r.RawBody()
+	defer body.Close()
+	if r.IsError() {
+		msg, _ := io.ReadAll(body)
+		return llm.Reply{}, fmt.Errorf("openai chat error: %s — %s", r.Status(), msg)
+	}
+
+	var (
+		content, reasoning strings.Builder
+		parser             llm.ThinkParser
+	)
+	emit := func(d llm.Delta) error {
+		if d.Content == "" && d.Reasoning == "" {
+			return nil
+		}
+		content.WriteString(d.Content)
+		reasoning.WriteString(d.Reasoning)
+		if fn != nil {
+			return fn(d)
+		}
+		return nil
+	}
+	reply := func() llm.Reply {
+		return llm.Reply{Content: content.String(), Reasoning: reasoning.String()}
+	}
+
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue // 空行、注释行（": keep-alive"）以及 event: 行
+		}
+		data = bytes.TrimSpace(data)
+		if string(data) == "[DONE]" {
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta message `json:"delta"`
+			} `json:"choices"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return reply(), fmt.Errorf("解析 openai 流失败: %w", err)
+		}
+		if chunk.Error != nil {
+			return reply(), fmt.Errorf("openai chat error: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.reasoning() != "" {
+			parser.Separate()
+		}
+		if err := emit(llm.Delta{Reasoning: delta.reasoning()}); err != nil {
+			return reply(), err
+		}
+		if err := emit(parser.Feed(delta.Content)); err != nil {
+			return reply(), err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return reply(), err
+	}
+	if err := emit(parser.Flush()); err != nil {
+		return reply(), err
+	}
+	return reply(), nil
+}
+
+// Generate 调用 /completions，只返回去掉 <think> 推理过程后的文本；
+// options 中的 num_predict 换算为 max_tokens，其余同名字段原样透传
+func (c *Client) Generate(ctx context.Context, model, prompt string, options map[string]interface{}) (string, error) {
+	if model == "" {
+		model = c.model
+	}
+	reqBody := map[string]interface{}{
+		"model":  model,
+		"prompt": prompt,
+		"stream": false,
+	}
+	for k, v := range options {
+		if k == "num_predict" {
+			k = "max_tokens"
+		}
+		reqBody[k] = v
+	}
+
+	var resp struct {
+		Choices []struct {
+			Text string `json:"text"`
+		} `json:"choices"`
+	}
+	r, err := c.cli.R().
+		SetContext(ctx).
+		SetBody(reqBody).
+		SetResult(&resp).
+		Post("/completions")
+	if err != nil {
+		return "", err
+	}
+	if r.IsError() {
+		return "", errorf("completions", r)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("openai completions 返回空的 choices")
+	}
+	return llm.SplitThink(resp.Choices[0].Text).Content, nil
+}
+
+// Embeddings 为单段文本生成向量
+func (c *Client) Embeddings(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+// EmbedBatch 调用 /embeddings，按返回的 index 还原顺序
+func (c *Client) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	reqBody := map[string]interface{}{
+		"model": c.embedModel,
+		"input": texts,
+	}
+
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	r, err := c.cli.R().
+		SetContext(ctx).
+		SetBody(reqBody).
+		SetResult(&resp).
+		Post("/embeddings")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, errorf("embeddings", r)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("openai embeddings 返回 %d 个向量，期望 %d 个", len(resp.Data), len(texts))
+	}
+	sort.SliceStable(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+	out := make([][]float32, len(resp.Data))
+	for i, d := range resp.Data {
+		out[i] = d.Embedding
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/llm"
+	"github.com/iammm0/physics-llm/internal/llm/openai"
+	"github.com/iammm0/physics-llm/internal/ollama"
+)
+
+// 可选的推理服务（LLM_PROVIDER / EMBED_PROVIDER）
+const (
+	Ollama = "ollama" // Ollama 原生 API（OLLAMA_*）
+	OpenAI = "openai" // OpenAI 兼容 API：llama.cpp server、vLLM、LM Studio 等（OPENAI_*）
+)
+
+// NewChatModel 按 LLM_PROVIDER 创建聊天模型
+func NewChatModel(cfg *config.Config) (llm.ChatModel, error) {
+	switch cfg.LLMProvider {
+	case Ollama, "":
+		return ollama.NewClient(cfg), nil
+	case OpenAI:
+		return openai.NewClient(cfg), nil
+	}
+	return nil, fmt.Errorf("未知的 LLM_PROVIDER: %s", cfg.LLMProvider)
+}
+
+// NewEmbedder 按 EMBED_PROVIDER 创建 Embedding 模型；聊天与 Embedding 可以来自不同的服务
+func NewEmbedder(cfg *config.Config) (llm.Embedder, error) {
+	switch cfg.EmbedProvider {
+	case Ollama, "":
+		return ollama.NewClient(cfg), nil
+	case OpenAI:
+		return openai.NewClient(cfg), nil
+	}
+	return nil, fmt.Errorf("未知的 EMBED_PROVIDER: %s", cfg.EmbedProvider)
+}
+
+// EmbedModel 当前 EMBED_PROVIDER 使用的 Embedding 模型名，记录在点的 payload 与导入清单中
+func EmbedModel(cfg *config.Config) string {
+	if cfg.EmbedProvider == OpenAI {
+		return cfg.OpenAIEmbedModel
+	}
+	return cfg.OllamaEmbedModel
+}
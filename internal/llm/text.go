@@ -0,0 +1,47 @@
+package llm
+
+import "unicode"
+
+// EstimateTokens 近似 BERT 类 WordPiece 分词的 token 数：
+// CJK 字符各算 1 个，英文单词按每 4 个字母约 1 个，数字每 3 位约 1 个，其它符号各算 1 个。
+// 不依赖具体分词器，用于 token 切片与历史截断的预算控制。
+func EstimateTokens(s string) int {
+	tokens, word, digits := 0, 0, 0
+	end := func() {
+		tokens += (word+3)/4 + (digits+2)/3
+		word, digits = 0, 0
+	}
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			end()
+			tokens++
+		case unicode.IsLetter(r):
+			if digits > 0 {
+				end()
+			}
+			word++
+		case unicode.IsDigit(r):
+			if word > 0 {
+				end()
+			}
+			digits++
+		case unicode.IsSpace(r):
+			end()
+		default:
+			end()
+			tokens++
+		}
+	}
+	end()
+	return tokens
+}
+
+// TruncateRunes 按字符截取前 n 个，截断时末尾加省略号
+func TruncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
@@ -0,0 +1,108 @@
+package llm
+
+import "strings"
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+/*
+ThinkParser 从模型输出中分离 <think>…</think> 推理内容，支持流式输入
+
+标签可能被拆在相邻两段增量里，末尾疑似标签开头的部分会暂存到下一段再判断。
+推理结束后紧跟的空行不计入回答。
+有些模型的对话模板自带 <think>，输出里只有 </think>：第一段增量中、任何 <think> 之前出现的 </think>
+之前的内容都算推理。为了不拖慢流式输出，开头只在疑似 <think> 的前几个字节时暂存，
+因此跨越多段增量的无开头推理只在完整输出（SplitThink）中能识别。
+*/
+type ThinkParser struct {
+	buf      string
+	started  bool // 已过开头，不再检查无 <think> 的推理
+	inThink  bool
+	trimLead bool // 刚结束推理，回答开头的空白需要去掉
+}
+
+// Separate 推理由服务端放在单独字段里（Ollama 的 thinking、OpenAI 兼容服务的 reasoning_content）时调用：
+// 回答中不会再有无开头的推理，直接按回答输出
+func (p *ThinkParser) Separate() { p.started = true }
+
+// Feed 输入一段增量，返回其中可以确定归属的回答与推理内容
+func (p *ThinkParser) Feed(chunk string) Delta {
+	p.buf += chunk
+	var content, reasoning strings.Builder
+	for {
+		if p.inThink {
+			if i := strings.Index(p.buf, thinkClose); i >= 0 {
+				reasoning.WriteString(p.buf[:i])
+				p.buf = p.buf[i+len(thinkClose):]
+				p.inThink, p.trimLead = false, true
+				continue
+			}
+			n := len(p.buf) - partialTag(p.buf, thinkClose)
+			reasoning.WriteString(p.buf[:n])
+			p.buf = p.buf[n:]
+			break
+		}
+
+		if p.trimLead {
+			p.buf = strings.TrimLeft(p.buf, " \t\r\n")
+			if p.buf == "" {
+				break
+			}
+			p.trimLead = false
+		}
+		if !p.started {
+			open, end := strings.Index(p.buf, thinkOpen), strings.Index(p.buf, thinkClose)
+			if end >= 0 && (open < 0 || end < open) {
+				reasoning.WriteString(p.buf[:end])
+				p.buf = p.buf[end+len(thinkClose):]
+				p.started, p.trimLead = true, true
+				continue
+			}
+			if strings.HasPrefix(thinkOpen, p.buf) {
+				break // 可能是被拆开的 <think>，等下一段
+			}
+			p.started = true
+		}
+		if i := strings.Index(p.buf, thinkOpen); i >= 0 {
+			content.WriteString(p.buf[:i])
+			p.buf = p.buf[i+len(thinkOpen):]
+			p.inThink = true
+			continue
+		}
+		n := len(p.buf) - partialTag(p.buf, thinkOpen)
+		content.WriteString(p.buf[:n])
+		p.buf = p.buf[n:]
+		break
+	}
+	return Delta{Content: content.String(), Reasoning: reasoning.String()}
+}
+
+// Flush 输出结束时调用，返回暂存的剩余内容；未闭合的 <think> 视为推理，始终没有标签的视为回答
+func (p *ThinkParser) Flush() Delta {
+	rest := p.buf
+	p.buf = ""
+	if p.inThink {
+		return Delta{Reasoning: rest}
+	}
+	return Delta{Content: rest}
+}
+
+// partialTag s 末尾与 tag 前缀重合的最大长度，如 "abc</th" 与 "</think>" 重合 4 个字节
+func partialTag(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// SplitThink 从完整输出中分离推理内容，等价于一次 Feed 加 Flush
+func SplitThink(s string) Reply {
+	var p ThinkParser
+	d := p.Feed(s)
+	rest := p.Flush()
+	return Reply{Content: d.Content + rest.Content, Reasoning: d.Reasoning + rest.Reasoning}
+}
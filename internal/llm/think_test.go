@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThinkParser(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunks    []string
+		content   string
+		reasoning string
+	}{
+		{"纯文本", []string{"Hello", " world"}, "Hello world", ""},
+		{"带小于号的纯文本", []string{"a < b", " <thin", "g>"}, "a < b <thing>", ""},
+		{"完整标签", []string{"<think>hmm</think>\n\nHello"}, "Hello", "hmm"},
+		{"标签拆在多段", []string{"<thi", "nk>hmm</th", "ink>\n\nHello", " world [1]"}, "Hello world [1]", "hmm"},
+		{"缺少开头标签", []string{"a < b and c</think> d"}, "d", "a < b and c"},
+		{"开头是被拆开的 <think>", []string{"<", "th", "ink>x</think>y"}, "y", "x"},
+		{"未闭合的推理", []string{"<think>still ", "thinking"}, "", "still thinking"},
+		{"推理前有回答", []string{"pre<think>x</think>post"}, "prepost", "x"},
+		{"空输出", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				p                  ThinkParser
+				content, reasoning strings.Builder
+			)
+			for _, c := range tt.chunks {
+				d := p.Feed(c)
+				content.WriteString(d.Content)
+				reasoning.WriteString(d.Reasoning)
+			}
+			d := p.Flush()
+			content.WriteString(d.Content)
+			reasoning.WriteString(d.Reasoning)
+			if content.String() != tt.content || reasoning.String() != tt.reasoning {
+				t.Errorf("got content %q reasoning %q, want %q / %q", content.String(), reasoning.String(), tt.content, tt.reasoning)
+			}
+
+			got := SplitThink(strings.Join(tt.chunks, ""))
+			if got.Content != tt.content || got.Reasoning != tt.reasoning {
+				t.Errorf("SplitThink = %+v, want content %q reasoning %q", got, tt.content, tt.reasoning)
+			}
+		})
+	}
+}
+
+// 没有标签的回答在第一次 Feed 时就原样输出，不等后续增量
+func TestThinkParserStreamsPlainText(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   string
+		content string
+	}{
+		{"纯文本", "Hello", "Hello"},
+		{"小于号开头", "<0.5 V", "<0.5 V"},
+		{"疑似标签只暂存重合部分", "Hi <thi", "Hi "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p ThinkParser
+			if d := p.Feed(tt.first); d.Content != tt.content || d.Reasoning != "" {
+				t.Errorf("Feed(%q) = %+v, want content %q", tt.first, d, tt.content)
+			}
+		})
+	}
+}
+
+// 开头之后才出现的无开头 </think> 无法再归为推理，按原文输出；SplitThink 对完整输出仍能识别
+func TestThinkParserOrphanAcrossChunks(t *testing.T) {
+	var p ThinkParser
+	d1, d2 := p.Feed("a < b and c"), p.Feed("</think> d")
+	if got := d1.Content + d2.Content + p.Flush().Content; got != "a < b and c</think> d" {
+		t.Errorf("stream content = %q", got)
+	}
+	if got := SplitThink("a < b and c</think> d"); got.Content != "d" || got.Reasoning != "a < b and c" {
+		t.Errorf("SplitThink = %+v", got)
+	}
+}
+
+// 推理在单独字段时，回答中的 </think> 不再把前文当作推理
+func TestThinkParserSeparate(t *testing.T) {
+	var p ThinkParser
+	p.Separate()
+	if d := p.Feed("x</think>y"); d.Content != "x</think>y" || d.Reasoning != "" {
+		t.Errorf("Feed = %+v", d)
+	}
+}
@@ -1,25 +1,47 @@
 package ollama
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/llm"
 )
 
+// Client 调用 Ollama HTTP API，同时实现 llm.ChatModel 与 llm.Embedder
 type Client struct {
 	cli        *resty.Client
+	stream     *resty.Client // 流式请求专用，不设整体超时，靠 ctx 取消
 	model      string
 	embedModel string // embeddings
+	think      *bool  // 请求中的 think 参数，nil 表示不传（见 OLLAMA_THINK）
 }
 
-// ChatMessage 与 Ollama /api/chat JSON 保持一致
-type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+// message /api/chat 响应中的 message；开启 think 时推理过程在 thinking 字段
+type message struct {
+	Role     string `json:"role"`
+	Content  string `json:"content"`
+	Thinking string `json:"thinking"`
 }
 
+// ChatMessage 与 Ollama /api/chat JSON 保持一致
+type ChatMessage = llm.Message
+
+// StreamFunc 每收到一段增量内容时回调；返回错误会中止读取
+type StreamFunc = llm.StreamFunc
+
+var (
+	_ llm.ChatModel = (*Client)(nil)
+	_ llm.Embedder  = (*Client)(nil)
+)
+
 // NewClient === 对外构造器 ===
 func NewClient(cfg *config.Config) *Client {
 	c := resty.New().
@@ -27,54 +49,206 @@ func NewClient(cfg *config.Config) *Client {
 		SetTimeout(60*time.Second). // 可按需调整
 		SetHeader("Content-Type", "application/json")
 
-	return &Client{
+	// 流式生成可能持续数分钟，http.Client.Timeout 会连响应体一起计时，所以这里不设超时
+	s := resty.New().
+		SetBaseURL(cfg.OllamaURL).
+		SetHeader("Content-Type", "application/json")
+
+	cli := &Client{
 		cli:        c,
+		stream:     s,
 		model:      cfg.OllamaModel,
 		embedModel: cfg.OllamaEmbedModel, // 新增字段
 	}
+	switch strings.ToLower(cfg.OllamaThink) {
+	case "true":
+		cli.think = new(bool)
+		*cli.think = true
+	case "false":
+		cli.think = new(bool)
+	}
+	return cli
+}
+
+// body 组装 /api/chat、/api/generate 共用的请求字段
+func (c *Client) body(model string, stream bool) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":  model,
+		"stream": stream,
+	}
+	if c.think != nil {
+		body["think"] = *c.think
+	}
+	return body
 }
 
 /*
-Complete 发送聊天请求，返回 assistant 的 content
+Complete 发送聊天请求，返回 assistant 的 content（不含推理过程）
 
 prompt —— 用户问题，自动封装为 `{"role":"user", ...}`
 system —— 可选系统提示词；留空则不发送 system 消息
 */
-func (c *Client) Complete(prompt string, system string) (string, error) {
-	var msgs []ChatMessage
+func (c *Client) Complete(ctx context.Context, prompt string, system string) (string, error) {
+	reply, err := c.Chat(ctx, llm.Messages(prompt, system))
+	return reply.Content, err
+}
 
-	if system != "" {
-		msgs = append(msgs, ChatMessage{Role: "system", Content: system})
-	}
-	msgs = append(msgs, ChatMessage{Role: "user", Content: prompt})
+/*
+Chat 以完整 messages（可含多轮历史）调用 /api/chat
 
-	reqBody := map[string]interface{}{
-		"model":    c.model,
-		"messages": msgs,
-		"stream":   false,
-	}
+推理过程可能来自 message.thinking 字段（请求带 think=true 时），
+也可能以 <think>…</think> 内联在 content 开头（未传 think 的 deepseek-r1 等），两种都会移到 Reasoning。
+*/
+func (c *Client) Chat(ctx context.Context, msgs []ChatMessage) (llm.Reply, error) {
+	reqBody := c.body(c.model, false)
+	reqBody["messages"] = msgs
 
 	var resp struct {
-		Message ChatMessage `json:"message"` // 只关心 assistant 最终回复
+		Message message `json:"message"` // 只关心 assistant 最终回复
 	}
 
 	r, err := c.cli.R().
+		SetContext(ctx).
 		SetBody(reqBody).
 		SetResult(&resp).
 		Post("/api/chat")
+	if err != nil {
+		return llm.Reply{}, err
+	}
+	if r.IsError() {
+		return llm.Reply{}, fmt.Errorf("ollama chat error: %s", r.Status())
+	}
+	reply := llm.SplitThink(resp.Message.Content)
+	reply.Reasoning = resp.Message.Thinking + reply.Reasoning
+	return reply, nil
+}
+
+/*
+CompleteStream 与 Complete 相同，但以 `"stream": true` 调用 /api/chat
+
+Ollama 按行返回 NDJSON，每行携带一段增量 content（或 thinking），最后一行 done=true。
+每段增量都会回调 fn，推理与回答分别放在 Delta 的 Reasoning 与 Content；
+全部结束后返回拼接好的完整回复；ctx 取消时立即中断 HTTP 连接。
+*/
+func (c *Client) CompleteStream(ctx context.Context, prompt, system string, fn StreamFunc) (llm.Reply, error) {
+	return c.ChatStream(ctx, llm.Messages(prompt, system), fn)
+}
+
+// ChatStream 以完整 messages 流式调用 /api/chat，语义同 CompleteStream
+func (c *Client) ChatStream(ctx context.Context, msgs []ChatMessage, fn StreamFunc) (llm.Reply, error) {
+	reqBody := c.body(c.model, true)
+	reqBody["messages"] = msgs
+
+	r, err := c.stream.R().
+		SetContext(ctx).
+		SetBody(reqBody).
+		SetDoNotParseResponse(true).
+		Post("/api/chat")
+	if err != nil {
+		return llm.Reply{}, err
+	}
+	body := r.RawBody()
+	defer body.Close()
+	if r.IsError() {
+		msg, _ := io.ReadAll(body)
+		return llm.Reply{}, fmt.Errorf("ollama chat error: %s — %s", r.Status(), msg)
+	}
+
+	var (
+		content, reasoning strings.Builder
+		parser             llm.ThinkParser
+	)
+	emit := func(d llm.Delta) error {
+		if d.Content == "" && d.Reasoning == "" {
+			return nil
+		}
+		content.WriteString(d.Content)
+		reasoning.WriteString(d.Reasoning)
+		if fn != nil {
+			return fn(d)
+		}
+		return nil
+	}
+	reply := func() llm.Reply {
+		return llm.Reply{Content: content.String(), Reasoning: reasoning.String()}
+	}
+	if c.think != nil && *c.think {
+		parser.Separate() // 推理走 thinking 字段
+	}
+
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk struct {
+			Message message `json:"message"`
+			Done    bool    `json:"done"`
+			Error   string  `json:"error"`
+		}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return reply(), fmt.Errorf("解析 ollama 流失败: %w", err)
+		}
+		if chunk.Error != "" {
+			return reply(), fmt.Errorf("ollama chat error: %s", chunk.Error)
+		}
+		if chunk.Message.Thinking != "" {
+			parser.Separate()
+		}
+		if err := emit(llm.Delta{Reasoning: chunk.Message.Thinking}); err != nil {
+			return reply(), err
+		}
+		if err := emit(parser.Feed(chunk.Message.Content)); err != nil {
+			return reply(), err
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return reply(), err
+	}
+	if err := emit(parser.Flush()); err != nil {
+		return reply(), err
+	}
+	return reply(), nil
+}
+
+// Generate 以非流式调用 /api/generate，model 为空时使用聊天模型；options 原样透传（如 temperature、num_predict）。
+// 只返回去掉 <think> 推理过程后的内容
+func (c *Client) Generate(ctx context.Context, model, prompt string, options map[string]interface{}) (string, error) {
+	if model == "" {
+		model = c.model
+	}
+	reqBody := c.body(model, false)
+	reqBody["prompt"] = prompt
+	if options != nil {
+		reqBody["options"] = options
+	}
+
+	var resp struct {
+		Response string `json:"response"`
+	}
+	r, err := c.cli.R().
+		SetContext(ctx).
+		SetBody(reqBody).
+		SetResult(&resp).
+		Post("/api/generate")
 	if err != nil {
 		return "", err
 	}
 	if r.IsError() {
-		return "", fmt.Errorf("ollama chat error: %s", r.Status())
+		return "", fmt.Errorf("ollama generate error: %s", r.Status())
 	}
-	return resp.Message.Content, nil
+	return llm.SplitThink(resp.Response).Content, nil
 }
 
-// Embeddings 调 /api/embeddings，返回 float32 切片
-func (c *Client) Embeddings(text string) ([]float32, error) {
+// Embeddings 调 /api/embeddings，使用 OLLAMA_EMBED_MODEL，返回 float32 切片
+func (c *Client) Embeddings(ctx context.Context, text string) ([]float32, error) {
 	reqBody := map[string]string{
-		"model":  "mxbai-embed-large", // 你可写到 cfg 里
+		"model":  c.embedModel,
 		"prompt": text,
 	}
 
@@ -83,6 +257,7 @@ func (c *Client) Embeddings(text string) ([]float32, error) {
 	}
 
 	r, err := c.cli.R().
+		SetContext(ctx).
 		SetBody(reqBody).
 		SetResult(&resp).
 		Post("/api/embeddings")
@@ -94,3 +269,34 @@ func (c *Client) Embeddings(text string) ([]float32, error) {
 	}
 	return resp.Embedding, nil
 }
+
+// EmbedBatch 调 /api/embed，一次请求为多段文本生成向量，返回顺序与 texts 一致
+func (c *Client) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	reqBody := map[string]interface{}{
+		"model": c.embedModel,
+		"input": texts,
+	}
+
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+
+	r, err := c.cli.R().
+		SetContext(ctx).
+		SetBody(reqBody).
+		SetResult(&resp).
+		Post("/api/embed")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, fmt.Errorf("ollama embed error: %s — %s", r.Status(), r.String())
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama embed 返回 %d 个向量，期望 %d 个", len(resp.Embeddings), len(texts))
+	}
+	return resp.Embeddings, nil
+}
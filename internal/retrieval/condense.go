@@ -0,0 +1,68 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/iammm0/physics-llm/internal/llm"
+)
+
+// condenseTimeout 改写追问的超时，只占检索超时的一部分，超时后用原问题检索
+const condenseTimeout = 10 * time.Second
+
+// condenseAnswerRunes 写进改写 prompt 的每条助手回答最多保留的字数，回答往往很长，开头已足够说明话题
+const condenseAnswerRunes = 300
+
+const condensePrompt = `下面是学生与物理助教的对话记录，以及学生的最新追问。
+请结合对话，把最新追问改写成一条不依赖上下文、可以单独拿去检索课程资料的完整问题：
+补全代词和省略的对象，写明涉及的实验、步骤或物理量。如果追问本身已经完整，原样输出。
+不要回答问题，只输出改写后的问题。
+
+对话记录：
+%s
+
+最新追问：%s`
+
+/*
+Condense 结合最近 CONDENSE_TURNS 轮对话，把追问（如"那第二步呢？"）改写成可独立检索的完整问题
+
+没有历史或未启用时原样返回 question；改写有单独的 condenseTimeout，失败（包括超时）只记日志并退回原问题，
+只有客户端断开、ctx 被取消时返回错误。
+*/
+func (r *Retriever) Condense(ctx context.Context, history []llm.Message, question string) (string, error) {
+	if r.condenseTurns <= 0 || len(history) == 0 {
+		return question, nil
+	}
+	if n := len(history) - r.condenseTurns*2; n > 0 {
+		history = history[n:]
+	}
+
+	lines := make([]string, 0, len(history))
+	for _, m := range history {
+		switch m.Role {
+		case "user":
+			lines = append(lines, "学生："+m.Content)
+		case "assistant":
+			lines = append(lines, "助教："+llm.TruncateRunes(strings.TrimSpace(llm.SplitThink(m.Content).Content), condenseAnswerRunes))
+		}
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, condenseTimeout)
+	defer cancel()
+	out, err := llm.Complete(cctx, r.chat, fmt.Sprintf(condensePrompt, strings.Join(lines, "\n"), question), "")
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return "", ctx.Err()
+		}
+		log.Printf("改写追问失败，使用原问题检索: %v\n", err)
+		return question, nil
+	}
+	if q := firstLine(out); q != "" {
+		return q, nil
+	}
+	return question, nil
+}
@@ -0,0 +1,205 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iammm0/physics-llm/internal/llm"
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// 检索前的查询扩展方式（QUERY_EXPANSION，或请求里的 expand 字段），可以组合使用
+const (
+	ExpandNone    = "none"    // 不扩展
+	ExpandRewrite = "rewrite" // 把口语化的问题改写成规范、完整的检索语句
+	ExpandMulti   = "multi"   // 生成若干个不同说法的同义问题
+	ExpandHyDE    = "hyde"    // 先让模型写一段假想答案，用它的向量去检索（Hypothetical Document Embeddings）
+)
+
+// expandTimeout 查询扩展的超时，只占检索超时的一部分，超时后用原问题检索
+const expandTimeout = 10 * time.Second
+
+// ErrUnknownExpansion 请求了未知的查询扩展方式
+var ErrUnknownExpansion = fmt.Errorf("未知的查询扩展方式")
+
+const (
+	rewritePrompt = `把下面这个学生提出的物理问题改写成一条适合在课程资料中检索的规范问句：
+补全省略的主语和物理量，口语词换成术语，不要回答问题。只输出改写后的问句。
+
+问题：%s`
+
+	multiPrompt = `针对下面这个物理问题，写出 %d 个意思相同但措辞、角度不同的问法，用于在课程资料中检索。
+每行一个，不要编号，不要回答问题，不要输出其它内容。
+
+问题：%s`
+
+	hydePrompt = `请用教材的语气写一段约 150 字的文字，直接回答下面的物理问题，可以包含相关的公式和术语。
+只输出这段文字。
+
+问题：%s`
+)
+
+// Expansion 一次查询扩展的结果，与原始问题一起参与召回
+type Expansion struct {
+	Rewrite     string   `json:"rewrite,omitempty"`     // 改写后的问题
+	Paraphrases []string `json:"paraphrases,omitempty"` // 同义问法
+	HyDE        string   `json:"hyde,omitempty"`        // 假想答案，只用于向量召回
+}
+
+// queries 参与召回的全部问句：原始问题在前，去掉重复项
+func (e *Expansion) queries(original string) []string {
+	out := []string{original}
+	seen := map[string]bool{original: true}
+	for _, q := range append([]string{e.Rewrite}, e.Paraphrases...) {
+		if q != "" && !seen[q] {
+			seen[q] = true
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// ValidateExpansion 检查扩展方式是否都合法
+func ValidateExpansion(modes []string) error {
+	for _, m := range modes {
+		switch m {
+		case ExpandNone, ExpandRewrite, ExpandMulti, ExpandHyDE:
+		default:
+			return fmt.Errorf("%w: %s", ErrUnknownExpansion, m)
+		}
+	}
+	return nil
+}
+
+/*
+Expand 按 modes 调用聊天模型扩展查询，modes 为空时使用 QUERY_EXPANSION 配置
+
+各方式并发请求；单个方式失败只记日志，不影响其它方式和后续检索。
+没有启用任何方式、全部失败或超过 expandTimeout 时返回 nil，此时按原始问题检索；
+只有客户端断开、ctx 被取消时返回错误。
+*/
+func (r *Retriever) Expand(ctx context.Context, query string, modes []string) (*Expansion, error) {
+	if len(modes) == 0 {
+		modes = r.expand
+	}
+	if err := ValidateExpansion(modes); err != nil {
+		return nil, err
+	}
+
+	ectx, cancel := context.WithTimeout(ctx, expandTimeout)
+	defer cancel()
+	var (
+		exp Expansion
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+	)
+	run := func(mode, prompt string, set func(out string)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := llm.Complete(ectx, r.chat, prompt, "")
+			if err != nil {
+				log.Printf("查询扩展（%s）失败: %v\n", mode, err)
+				return
+			}
+			mu.Lock()
+			set(out)
+			mu.Unlock()
+		}()
+	}
+	for _, m := range modes {
+		switch m {
+		case ExpandRewrite:
+			run(m, fmt.Sprintf(rewritePrompt, query), func(out string) { exp.Rewrite = firstLine(out) })
+		case ExpandMulti:
+			run(m, fmt.Sprintf(multiPrompt, r.paraphrases, query), func(out string) {
+				exp.Paraphrases = nonEmptyLines(out, r.paraphrases)
+			})
+		case ExpandHyDE:
+			if r.mode == ModeKeyword {
+				continue // 关键词召回用不上假想答案
+			}
+			run(m, fmt.Sprintf(hydePrompt, query), func(out string) { exp.HyDE = strings.TrimSpace(out) })
+		}
+	}
+	wg.Wait()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return nil, ctx.Err()
+	}
+	if ectx.Err() != nil {
+		log.Printf("查询扩展超时，使用原问题检索\n")
+		return nil, nil
+	}
+	if exp.Rewrite == "" && len(exp.Paraphrases) == 0 && exp.HyDE == "" {
+		return nil, nil
+	}
+	return &exp, nil
+}
+
+// recallExpanded 对原始问题与扩展出的每个问句分别召回（HyDE 只走向量），再按 RRF 合并
+func (r *Retriever) recallExpanded(ctx context.Context, query string, n int, opt Options, withVector bool) ([]store.Hit, error) {
+	queries := opt.Expansion.queries(query)
+	hyde := opt.Expansion.HyDE != "" && r.mode != ModeKeyword
+	lists := make([][]store.Hit, len(queries)+1)
+	errs := make([]error, len(queries)+1)
+
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			lists[i], errs[i] = r.recall(ctx, q, n, opt, withVector)
+		}(i, q)
+	}
+	if hyde {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i := len(queries)
+			lists[i], errs[i] = r.dense(ctx, opt.Expansion.HyDE, n, opt, withVector)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return truncate(FuseRRF(r.rrfK, lists...), n), nil
+}
+
+// listMarkerRe 行首的编号或列表符号，如 "1. "、"2、"、"- "；不会误删 "1.5 eV" 这类数值
+var listMarkerRe = regexp.MustCompile(`^(?:\d+(?:\.\s|[、)）])|[-*•])\s*`)
+
+// firstLine 取第一个非空行，并去掉模型常加的引号与前缀
+func firstLine(out string) string {
+	lines := nonEmptyLines(out, 1)
+	if len(lines) == 0 {
+		return ""
+	}
+	return lines[0]
+}
+
+// nonEmptyLines 取前 limit 个非空行，去掉行首的编号、列表符号与包裹的引号
+func nonEmptyLines(out string, limit int) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		line = listMarkerRe.ReplaceAllString(strings.TrimSpace(line), "")
+		line = strings.TrimPrefix(line, "改写后的问句：")
+		line = strings.Trim(line, "\"'“”「」 ")
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) == limit {
+			break
+		}
+	}
+	return lines
+}
@@ -0,0 +1,41 @@
+package retrieval
+
+import (
+	"sort"
+
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// DefaultRRFK RRF 的平滑常数，取原论文的 60
+const DefaultRRFK = 60
+
+// FuseRRF 用倒数排名融合（Reciprocal Rank Fusion）合并多路召回结果：
+// 每个片段的得分为 Σ 1/(k + rank)，rank 从 1 开始；同一片段以 ID 去重，保留最先出现的那份字段。
+// 返回结果的 Score 为融合得分，不再是余弦相似度或 BM25 分数。
+func FuseRRF(k int, lists ...[]store.Hit) []store.Hit {
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+	scores := map[string]float64{}
+	first := map[string]store.Hit{}
+	var order []string
+	for _, list := range lists {
+		for rank, h := range list {
+			if _, ok := first[h.ID]; !ok {
+				first[h.ID] = h
+				order = append(order, h.ID)
+			}
+			scores[h.ID] += 1 / float64(k+rank+1)
+		}
+	}
+	// 稳定排序：得分相同的按首次出现的顺序，向量召回排在前面的优先
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	fused := make([]store.Hit, len(order))
+	for i, id := range order {
+		h := first[id]
+		h.Score = float32(scores[id])
+		fused[i] = h
+	}
+	return fused
+}
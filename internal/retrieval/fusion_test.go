@@ -0,0 +1,47 @@
+package retrieval
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+func TestFuseRRF(t *testing.T) {
+	hits := func(ids ...string) []store.Hit {
+		out := make([]store.Hit, len(ids))
+		for i, id := range ids {
+			out[i] = store.Hit{ID: id, Text: id}
+		}
+		return out
+	}
+	tests := []struct {
+		name  string
+		k     int
+		lists [][]store.Hit
+		want  []string
+	}{
+		{"单路保持原顺序", 60, [][]store.Hit{hits("a", "b", "c")}, []string{"a", "b", "c"}},
+		{"两路都出现的排在前面", 60, [][]store.Hit{hits("a", "b", "c"), hits("c", "d")}, []string{"c", "a", "b", "d"}},
+		{"同分按首次出现", 60, [][]store.Hit{hits("a"), hits("b")}, []string{"a", "b"}},
+		{"k 非法时用默认值", 0, [][]store.Hit{hits("a", "b"), hits("b")}, []string{"b", "a"}},
+		{"空输入", 60, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, h := range FuseRRF(tt.k, tt.lists...) {
+				got = append(got, h.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FuseRRF = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	fused := FuseRRF(60, hits("a", "b"), hits("b"))
+	if want := 1.0/62 + 1.0/61; math.Abs(float64(fused[0].Score)-want) > 1e-6 {
+		t.Errorf("b 的融合得分 = %v, want %v", fused[0].Score, want)
+	}
+}
@@ -0,0 +1,106 @@
+package retrieval
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// 高亮标记，片段中的其它内容会做 HTML 转义，前端可以直接当 HTML 渲染
+const (
+	HighlightPre  = "<em>"
+	HighlightPost = "</em>"
+)
+
+// highlightTerms 从查询中取出用于高亮的词：字母 / 数字词整体匹配，
+// 连续汉字按两字一组匹配（单个汉字太容易误命中，只有整段查询只有一个汉字时才使用）
+func highlightTerms(query string) []string {
+	var terms []string
+	for _, t := range Tokenize(query) {
+		r := []rune(t)
+		if len(r) == 1 && unicode.Is(unicode.Han, r[0]) {
+			continue
+		}
+		terms = append(terms, t)
+	}
+	if len(terms) == 0 {
+		for _, r := range query {
+			if unicode.Is(unicode.Han, r) {
+				terms = append(terms, string(r))
+			}
+		}
+	}
+	return terms
+}
+
+/*
+Highlight 截取 text 中与 query 最相关的一段（至多 width 个字符），并用 <em></em> 标出命中的查询词
+
+窗口选在命中字符最多的位置；截断处补 "…"。没有命中时返回开头的 width 个字符。
+*/
+func Highlight(text, query string, width int) string {
+	r := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(r) {
+		lower = r // 极少数字符大小写转换后长度变化，退化为区分大小写匹配
+	}
+
+	// 标记命中的字符
+	hit := make([]bool, len(r))
+	for _, term := range highlightTerms(query) {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == term {
+				for j := i; j < i+len(t); j++ {
+					hit[j] = true
+				}
+			}
+		}
+	}
+
+	// 滑动窗口找命中字符最多的起点
+	start := 0
+	if width > 0 && len(r) > width {
+		count, best := 0, -1
+		for i := 0; i < len(r); i++ {
+			if hit[i] {
+				count++
+			}
+			if i >= width && hit[i-width] {
+				count--
+			}
+			if i >= width-1 && count > best {
+				best, start = count, i-width+1
+			}
+		}
+		// 窗口往前挪一些，让第一个命中词前面带点上下文
+		for i := start; i < start+width; i++ {
+			if hit[i] {
+				start = max(0, min(i-width/4, len(r)-width))
+				break
+			}
+		}
+	}
+	end := len(r)
+	if width > 0 && start+width < end {
+		end = start + width
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if hit[i] && (i == start || !hit[i-1]) {
+			sb.WriteString(HighlightPre)
+		}
+		sb.WriteString(html.EscapeString(string(r[i])))
+		if hit[i] && (i == end-1 || !hit[i+1]) {
+			sb.WriteString(HighlightPost)
+		}
+	}
+	if end < len(r) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
@@ -0,0 +1,316 @@
+package retrieval
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Doc 写入关键词索引的一个片段，ID 与向量库中的点 ID 一致
+type Doc struct {
+	ID      string                 `json:"id"`
+	Payload map[string]interface{} `json:"payload"` // 与向量库 payload 相同，text 字段用于建索引
+}
+
+// Index 进程内的 BM25 倒排索引，按 source 整体替换 / 删除，持久化为 JSON。
+// API 与 cmd/ingest 可能是两个进程：每次读写前检查文件修改时间，被另一进程更新过就重新加载。
+type Index struct {
+	mu       sync.RWMutex
+	path     string
+	modTime  time.Time                 // 最近一次加载或写入时文件的修改时间
+	sources  map[string][]string       // source → 片段 ID
+	docs     map[string]*indexedDoc    // 片段 ID → 文档
+	postings map[string]map[string]int // 词 → 片段 ID → 词频
+	totalLen int                       // 全部片段的词数之和，用于计算平均长度
+}
+
+type indexedDoc struct {
+	Doc
+	source string
+	length int
+	terms  map[string]int
+}
+
+// indexFile 索引文件的磁盘格式：只存原文，倒排表在加载时重建
+type indexFile struct {
+	Sources map[string][]Doc `json:"sources"`
+}
+
+// LoadIndex 读取索引文件，不存在时返回空索引
+func LoadIndex(path string) (*Index, error) {
+	idx := &Index{path: path}
+	idx.reset()
+	if err := idx.load(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *Index) reset() {
+	idx.sources = map[string][]string{}
+	idx.docs = map[string]*indexedDoc{}
+	idx.postings = map[string]map[string]int{}
+	idx.totalLen = 0
+}
+
+// load 从磁盘重建索引；调用方需持有写锁（LoadIndex 除外）
+func (idx *Index) load() error {
+	info, err := os.Stat(idx.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(idx.path)
+	if err != nil {
+		return err
+	}
+	var f indexFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("解析关键词索引 %s 失败: %w", idx.path, err)
+	}
+	idx.reset()
+	for source, docs := range f.Sources {
+		idx.add(source, docs)
+	}
+	idx.modTime = info.ModTime()
+	return nil
+}
+
+// refresh 文件被其它进程改写过时重新加载；调用方需持有写锁
+func (idx *Index) refresh() error {
+	info, err := os.Stat(idx.path)
+	if err != nil || info.ModTime().Equal(idx.modTime) {
+		return nil // 文件还不存在或没有变化
+	}
+	return idx.load()
+}
+
+// Has 判断某个 source 是否已建索引
+func (idx *Index) Has(source string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	_ = idx.refresh()
+	_, ok := idx.sources[source]
+	return ok
+}
+
+// Put 用 docs 替换某个 source 的全部片段并立即落盘
+func (idx *Index) Put(source string, docs []Doc) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if err := idx.refresh(); err != nil {
+		return err
+	}
+	idx.remove(source)
+	idx.add(source, docs)
+	return idx.save()
+}
+
+// Delete 移除某个 source 的全部片段并立即落盘
+func (idx *Index) Delete(source string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if err := idx.refresh(); err != nil {
+		return err
+	}
+	if _, ok := idx.sources[source]; !ok {
+		return nil
+	}
+	idx.remove(source)
+	return idx.save()
+}
+
+func (idx *Index) add(source string, docs []Doc) {
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		text, _ := d.Payload["text"].(string)
+		terms := map[string]int{}
+		toks := Tokenize(text)
+		for _, t := range toks {
+			terms[t]++
+		}
+		for t, tf := range terms {
+			if idx.postings[t] == nil {
+				idx.postings[t] = map[string]int{}
+			}
+			idx.postings[t][d.ID] = tf
+		}
+		idx.docs[d.ID] = &indexedDoc{Doc: d, source: source, length: len(toks), terms: terms}
+		idx.totalLen += len(toks)
+		ids = append(ids, d.ID)
+	}
+	idx.sources[source] = ids
+}
+
+func (idx *Index) remove(source string) {
+	for _, id := range idx.sources[source] {
+		d, ok := idx.docs[id]
+		if !ok {
+			continue
+		}
+		for t := range d.terms {
+			delete(idx.postings[t], id)
+			if len(idx.postings[t]) == 0 {
+				delete(idx.postings, t)
+			}
+		}
+		idx.totalLen -= d.length
+		delete(idx.docs, id)
+	}
+	delete(idx.sources, source)
+}
+
+// save 写临时文件后 rename；调用方需持有写锁
+func (idx *Index) save() error {
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0o755); err != nil {
+		return err
+	}
+	f := indexFile{Sources: make(map[string][]Doc, len(idx.sources))}
+	for source, ids := range idx.sources {
+		docs := make([]Doc, 0, len(ids))
+		for _, id := range ids {
+			docs = append(docs, idx.docs[id].Doc)
+		}
+		f.Sources[source] = docs
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp := idx.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, idx.path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(idx.path); err == nil {
+		idx.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Len 已索引的片段数
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Search 按 BM25 打分返回满足 filter 的前 limit 个片段，filter 可为 nil。
+// BM25 得分没有上界，无法直接设阈值；minMatch 大于 0 时改为要求片段至少命中这一比例的查询词（去重后）。
+func (idx *Index) Search(query string, limit int, filter *store.Filter, minMatch float64) []store.Hit {
+	idx.mu.Lock()
+	if err := idx.refresh(); err != nil {
+		// 读坏了就继续用内存中的旧索引，下次再试
+		log.Printf("重新加载关键词索引失败: %v\n", err)
+	}
+	idx.mu.Unlock()
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	n := len(idx.docs)
+	if n == 0 || limit <= 0 {
+		return nil
+	}
+	avgLen := float64(idx.totalLen) / float64(n)
+
+	scores := map[string]float64{}
+	matched := map[string]int{}
+	seen := map[string]bool{}
+	for _, t := range Tokenize(query) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		posting := idx.postings[t]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (float64(n)-df+0.5)/(df+0.5))
+		for id, tf := range posting {
+			if !filter.Match(idx.docs[id].Payload) {
+				continue
+			}
+			dl := float64(idx.docs[id].length)
+			f := float64(tf)
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*dl/avgLen))
+			matched[id]++
+		}
+	}
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		if float64(matched[id]) < minMatch*float64(len(seen)) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	hits := make([]store.Hit, len(ids))
+	for i, id := range ids {
+		hits[i] = store.NewHit(id, float32(scores[id]), idx.docs[id].Payload)
+	}
+	return hits
+}
+
+// Tokenize 面向中英文混排的分词：汉字输出单字与相邻两字的 bigram，
+// 其它字母 / 数字连续成词并转小写，这样 "SR830"、"μ0"、"B-H" 中的 "b"、"h" 都能被精确匹配
+func Tokenize(s string) []string {
+	var (
+		toks    []string
+		word    []rune
+		prevHan rune
+	)
+	flush := func() {
+		if len(word) > 0 {
+			toks = append(toks, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			toks = append(toks, string(r))
+			if prevHan != 0 {
+				toks = append(toks, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			word = append(word, unicode.ToLower(r))
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+	return toks
+}
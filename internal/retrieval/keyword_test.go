@@ -0,0 +1,87 @@
+package retrieval
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"SR830 Lock-in", []string{"sr830", "lock", "in"}},
+		{"μ0 与 B-H", []string{"μ0", "与", "b", "h"}},
+		{"光栅常数", []string{"光", "栅", "光栅", "常", "栅常", "数", "常数"}},
+		{"测量g值", []string{"测", "量", "测量", "g", "值"}},
+		{"2.5 kg", []string{"2", "5", "kg"}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx, err := LoadIndex(filepath.Join(t.TempDir(), "kw.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := func(id, text, course string) Doc {
+		return Doc{ID: id, Payload: map[string]interface{}{"text": text, "source": course + ".md", "course": course}}
+	}
+	if err := idx.Put("optics.md", []Doc{
+		doc("a", "光栅衍射实验测量光栅常数", "optics"),
+		doc("b", "迈克尔逊干涉仪测量波长", "optics"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Put("lockin.md", []Doc{
+		doc("c", "SR830 锁相放大器的时间常数设置", "lockin"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		filter   *store.Filter
+		minMatch float64
+		want     []string
+	}{
+		{"按 BM25 排序", "光栅常数", nil, 0, []string{"a", "c"}},
+		{"英文型号", "sr830", nil, 0, []string{"c"}},
+		{"过滤", "光栅常数", &store.Filter{Must: store.Conditions{"course": {"lockin"}}}, 0, []string{"c"}},
+		{"minMatch 去掉只命中少数词的片段", "光栅常数", nil, 0.5, []string{"a"}},
+		{"minMatch 为 1 要求全部命中", "测量波长", nil, 1, []string{"b"}},
+		{"没有命中", "超导", nil, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, h := range idx.Search(tt.query, 10, tt.filter, tt.minMatch) {
+				got = append(got, h.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// 删除后另一个实例重新加载，看到的是最新内容
+	if err := idx.Delete("optics.md"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := LoadIndex(idx.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Len() != 1 || other.Has("optics.md") {
+		t.Errorf("重新加载后 Len = %d, Has(optics.md) = %v", other.Len(), other.Has("optics.md"))
+	}
+}
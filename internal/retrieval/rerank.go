@@ -0,0 +1,237 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iammm0/physics-llm/internal/llm"
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// 可选的重排器（RERANKER，或请求里的 rerank 字段）
+const (
+	RerankNone  = "none"          // 不重排，直接取召回的前 topK
+	RerankMMR   = "mmr"           // 最大边际相关性：在相关性与多样性之间折中，无需模型
+	RerankCross = "cross-encoder" // 小型生成模型逐条给 (query, 片段) 打分
+	RerankLLM   = "llm"           // 聊天模型一次性给全部候选打分（LLM-as-judge）
+)
+
+// ErrUnknownReranker 请求了未知的重排器
+var ErrUnknownReranker = fmt.Errorf("未知的重排器")
+
+// Reranker 对召回的候选片段重新排序，返回前 topK 个
+type Reranker interface {
+	Rerank(ctx context.Context, query string, hits []store.Hit, topK int) ([]store.Hit, error)
+}
+
+// truncate 截取前 topK 个
+func truncate(hits []store.Hit, topK int) []store.Hit {
+	if len(hits) > topK {
+		return hits[:topK]
+	}
+	return hits
+}
+
+// sortByScore 按 scores 降序重排 hits，并把 Score 改为重排得分
+func sortByScore(hits []store.Hit, scores []float64) []store.Hit {
+	order := make([]int, len(hits))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	out := make([]store.Hit, len(hits))
+	for i, j := range order {
+		out[i] = hits[j]
+		out[i].Score = float32(scores[j])
+	}
+	return out
+}
+
+// noRerank 保持召回顺序
+type noRerank struct{}
+
+func (noRerank) Rerank(_ context.Context, _ string, hits []store.Hit, topK int) ([]store.Hit, error) {
+	return truncate(hits, topK), nil
+}
+
+/*
+mmrReranker 最大边际相关性
+
+每一步选出 λ·rel(d) − (1−λ)·max sim(d, 已选) 最大的片段。
+rel 取候选在召回结果中的名次（1 − rank/n），这样对向量、BM25、融合得分都适用；
+sim 为两个片段向量的余弦相似度，没有向量的片段（如只被关键词召回）视为与其它片段不相似。
+*/
+type mmrReranker struct{ lambda float64 }
+
+func (m mmrReranker) Rerank(_ context.Context, _ string, hits []store.Hit, topK int) ([]store.Hit, error) {
+	n := len(hits)
+	if n <= 1 {
+		return hits, nil
+	}
+	rel := make([]float64, n)
+	for i := range hits {
+		rel[i] = 1 - float64(i)/float64(n)
+	}
+
+	used := make([]bool, n)
+	var selected []int
+	var out []store.Hit
+	for len(out) < topK && len(out) < n {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range hits {
+			if used[i] {
+				continue
+			}
+			maxSim := 0.0
+			for _, j := range selected {
+				maxSim = math.Max(maxSim, cosine(hits[i].Vector, hits[j].Vector))
+			}
+			if s := m.lambda*rel[i] - (1-m.lambda)*maxSim; s > bestScore {
+				best, bestScore = i, s
+			}
+		}
+		used[best] = true
+		selected = append(selected, best)
+		h := hits[best]
+		h.Score = float32(bestScore)
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+// cosine 余弦相似度，任一向量缺失或维度不一致时返回 0
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// crossEncoderParallel 同时在途的打分请求数，实际并行度还受 Ollama 的 OLLAMA_NUM_PARALLEL 限制
+const crossEncoderParallel = 4
+
+// crossEncoderPrompt reranker 模型的输入格式；要求只输出 0~1 的相关性分数
+const crossEncoderPrompt = "Query: %s\nDocument: %s\nRelevance score between 0 and 1:"
+
+// scoreRe 从模型输出中取第一个数字
+var scoreRe = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+/*
+crossEncoder 用 RERANK_MODEL 逐条给 (query, 片段) 打分
+
+Ollama 没有专门的 rerank 接口，这里通过 /api/generate 让模型直接输出分数，
+因此 RERANK_MODEL 必须是能按提示续写的生成模型（bge-reranker 这类分类模型不输出文本），
+且最好不是推理模型：num_predict 只有 8，推理过程会耗尽输出长度；
+单条打分失败时该候选排在已打分的候选之后，彼此保持召回顺序，不影响其它候选；
+全部失败时返回错误，由 Retrieve 退回召回顺序。
+*/
+type crossEncoder struct {
+	llm   llm.ChatModel
+	model string
+}
+
+func (c crossEncoder) Rerank(ctx context.Context, query string, hits []store.Hit, topK int) ([]store.Hit, error) {
+	if len(hits) == 0 {
+		return hits, nil
+	}
+	scores := make([]float64, len(hits))
+	scored := make([]bool, len(hits))
+	sem := make(chan struct{}, crossEncoderParallel)
+	var wg sync.WaitGroup
+	for i := range hits {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			out, err := c.llm.Generate(ctx, c.model, fmt.Sprintf(crossEncoderPrompt, query, hits[i].Text),
+				map[string]interface{}{"temperature": 0, "num_predict": 8})
+			if err != nil {
+				return
+			}
+			if s, err := strconv.ParseFloat(scoreRe.FindString(out), 64); err == nil && !math.IsInf(s, 0) {
+				scores[i], scored[i] = s, true
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 未打分的候选取比最低分再低 1 的有限值：Score 会写进响应 JSON，无穷大无法序列化
+	floor, ok := math.Inf(1), false
+	for i, s := range scores {
+		if scored[i] {
+			floor, ok = math.Min(floor, s), true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%d 个候选全部打分失败", len(hits))
+	}
+	for i := range scores {
+		if !scored[i] {
+			scores[i] = floor - 1
+		}
+	}
+	return truncate(sortByScore(hits, scores), topK), nil
+}
+
+// llmJudgePrompt 让聊天模型一次性给全部候选打 0~10 分，%s 依次为问题与编号后的片段
+const llmJudgePrompt = `你是检索结果评审。根据问题，为每个编号片段与问题的相关程度打 0~10 分（10 表示能直接回答问题）。
+只输出 JSON 对象，键为片段编号，值为分数，例如 {"1": 8, "2": 3}，不要输出其它内容。
+
+问题：%s
+
+%s`
+
+// llmJudge LLM-as-judge：一次请求给全部候选打分，比 crossEncoder 少很多次调用，但受上下文长度限制
+type llmJudge struct{ llm llm.ChatModel }
+
+func (j llmJudge) Rerank(ctx context.Context, query string, hits []store.Hit, topK int) ([]store.Hit, error) {
+	parts := make([]string, len(hits))
+	for i, h := range hits {
+		parts[i] = fmt.Sprintf("[%d] %s", i+1, h.Text)
+	}
+	out, err := llm.Complete(ctx, j.llm, fmt.Sprintf(llmJudgePrompt, query, strings.Join(parts, "\n\n")), "")
+	if err != nil {
+		return nil, fmt.Errorf("重排打分失败: %w", err)
+	}
+
+	// 模型可能在 JSON 前后附带说明文字，取最后一个 JSON 对象
+	raw := out[strings.LastIndex(out, "{")+1:]
+	if end := strings.Index(raw, "}"); end >= 0 {
+		raw = raw[:end]
+	}
+	var judged map[string]float64
+	if err := json.Unmarshal([]byte("{"+raw+"}"), &judged); err != nil {
+		return nil, fmt.Errorf("解析重排打分失败: %w", err)
+	}
+
+	// 没打分的候选排在最后，彼此保持召回顺序
+	scores := make([]float64, len(hits))
+	for i := range hits {
+		s, ok := judged[strconv.Itoa(i+1)]
+		if !ok {
+			s = -1
+		}
+		scores[i] = s
+	}
+	return truncate(sortByScore(hits, scores), topK), nil
+}
@@ -0,0 +1,183 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/iammm0/physics-llm/internal/config"
+	"github.com/iammm0/physics-llm/internal/llm"
+	"github.com/iammm0/physics-llm/internal/store"
+)
+
+// 召回模式（RETRIEVAL_MODE）
+const (
+	ModeVector  = "vector"  // 只用向量相似度
+	ModeKeyword = "keyword" // 只用 BM25 关键词索引
+	ModeHybrid  = "hybrid"  // 两路召回后做 RRF 融合
+)
+
+// Options 单次检索的参数
+type Options struct {
+	TopK     int
+	Offset   int           // 跳过排在前面的 Offset 个结果，用于分页
+	Rerank   string        // 重排器名称，留空使用 RERANKER 配置
+	Filter   *store.Filter // 可选的 payload 过滤条件，向量与关键词召回都会应用
+	MinScore float32       // 向量召回的最低余弦相似度，0 表示不限；见 recall 对关键词与混合模式的处理
+	// 可选的查询扩展结果（见 Expand），扩展出的问句与原始问题分别召回后合并；重排仍以原始问题为准
+	Expansion *Expansion
+}
+
+// Retriever /v1/chat 等接口共用的检索入口：召回 → 融合 → 重排
+type Retriever struct {
+	chat       llm.ChatModel
+	embedder   llm.Embedder
+	db         store.VectorStore
+	keywords   *Index
+	mode       string
+	candidates int // 融合前每一路召回的候选数
+	rrfK       int
+
+	rerankers     map[string]Reranker
+	defaultRerank string
+	rerankPool    int // 启用重排时召回的候选数
+
+	expand      []string // 默认的查询扩展方式
+	paraphrases int      // multi 方式生成的同义问法数
+
+	condenseTurns int // 改写追问时参考的最近对话轮数，0 表示不改写
+}
+
+// New 创建 Retriever；keywords 应与导入流程共用同一个索引实例
+func New(cfg *config.Config, chat llm.ChatModel, embedder llm.Embedder, db store.VectorStore, keywords *Index) (*Retriever, error) {
+	switch cfg.RetrievalMode {
+	case ModeVector, ModeKeyword, ModeHybrid:
+	default:
+		return nil, fmt.Errorf("未知的 RETRIEVAL_MODE: %s", cfg.RetrievalMode)
+	}
+	r := &Retriever{
+		chat:       chat,
+		embedder:   embedder,
+		db:         db,
+		keywords:   keywords,
+		mode:       cfg.RetrievalMode,
+		candidates: cfg.HybridCandidates,
+		rrfK:       cfg.RRFK,
+		rerankers: map[string]Reranker{
+			RerankNone:  noRerank{},
+			RerankMMR:   mmrReranker{lambda: cfg.MMRLambda},
+			RerankCross: crossEncoder{llm: chat, model: cfg.RerankModel},
+			RerankLLM:   llmJudge{llm: chat},
+		},
+		defaultRerank: cfg.Reranker,
+		rerankPool:    cfg.RerankCandidates,
+		expand:        cfg.QueryExpansion,
+		paraphrases:   max(cfg.QueryParaphrases, 1),
+		condenseTurns: cfg.CondenseTurns,
+	}
+	if r.defaultRerank == "" {
+		r.defaultRerank = RerankNone
+	}
+	if _, ok := r.rerankers[r.defaultRerank]; !ok {
+		return nil, fmt.Errorf("%w: RERANKER=%s", ErrUnknownReranker, cfg.Reranker)
+	}
+	if err := ValidateExpansion(r.expand); err != nil {
+		return nil, fmt.Errorf("QUERY_EXPANSION: %w", err)
+	}
+	return r, nil
+}
+
+// Retrieve 按配置的模式召回候选，再用指定的重排器排出第 opt.Offset 名起的 opt.TopK 个片段
+func (r *Retriever) Retrieve(ctx context.Context, query string, opt Options) ([]store.Hit, error) {
+	name := opt.Rerank
+	if name == "" {
+		name = r.defaultRerank
+	}
+	reranker, ok := r.rerankers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownReranker, name)
+	}
+	if err := opt.Filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 纯向量检索、不重排也不做查询扩展时，分页直接交给 Qdrant
+	if r.mode == ModeVector && name == RerankNone && opt.Expansion == nil {
+		vec, err := r.embedder.Embeddings(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("生成 Embedding 失败: %w", err)
+		}
+		hits, err := r.db.Search(ctx, store.SearchParams{Vector: vec, Limit: opt.TopK, Offset: opt.Offset,
+			ScoreThreshold: opt.MinScore, Filter: opt.Filter})
+		if err != nil {
+			return nil, fmt.Errorf("检索文档失败: %w", err)
+		}
+		return hits, nil
+	}
+
+	// 其余情况先排出前 Offset+TopK 个再切片；启用重排时召回更大的候选集，交给重排器挑选
+	want := opt.Offset + opt.TopK
+	n := want
+	if name != RerankNone {
+		n = max(r.rerankPool, want)
+	}
+	recall := r.recall
+	if opt.Expansion != nil {
+		recall = r.recallExpanded
+	}
+	hits, err := recall(ctx, query, n, opt, name == RerankMMR)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked, err := reranker.Rerank(ctx, query, hits, want)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		// 重排只是锦上添花，失败时退回召回顺序
+		log.Printf("重排（%s）失败，使用召回顺序: %v\n", name, err)
+		ranked = truncate(hits, want)
+	}
+	if opt.Offset >= len(ranked) {
+		return nil, nil
+	}
+	return ranked[opt.Offset:], nil
+}
+
+// recall 召回满足 opt.Filter 的前 n 个候选；withVector 为 true 时向量召回的结果带上向量。
+// 混合模式下若设置了 MinScore 而向量召回全部低于阈值，则不返回任何结果：
+// 关键词索引按单字切分中文，几乎总能命中一些片段，单凭它不足以说明知识库里有相关资料。
+// 关键词模式没有相似度，MinScore 改作片段须命中的查询词比例，如 0.5 表示至少命中一半的查询词。
+func (r *Retriever) recall(ctx context.Context, query string, n int, opt Options, withVector bool) ([]store.Hit, error) {
+	if r.mode == ModeKeyword {
+		return r.keywords.Search(query, n, opt.Filter, float64(opt.MinScore)), nil
+	}
+
+	limit := n
+	if r.mode == ModeHybrid {
+		limit = max(r.candidates, n)
+	}
+	dense, err := r.dense(ctx, query, limit, opt, withVector)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeVector || (opt.MinScore > 0 && len(dense) == 0) {
+		return dense, nil
+	}
+	return truncate(FuseRRF(r.rrfK, dense, r.keywords.Search(query, limit, opt.Filter, 0)), n), nil
+}
+
+// dense 向量召回：对 text 做 Embedding 后在 Qdrant 中检索前 limit 个
+func (r *Retriever) dense(ctx context.Context, text string, limit int, opt Options, withVector bool) ([]store.Hit, error) {
+	vec, err := r.embedder.Embeddings(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("生成 Embedding 失败: %w", err)
+	}
+	hits, err := r.db.Search(ctx, store.SearchParams{Vector: vec, Limit: limit, ScoreThreshold: opt.MinScore,
+		WithVector: withVector, Filter: opt.Filter})
+	if err != nil {
+		return nil, fmt.Errorf("检索文档失败: %w", err)
+	}
+	return hits, nil
+}
@@ -0,0 +1,309 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Embedded 纯 Go 的内嵌向量库：全部点常驻内存，检索时逐个计算余弦相似度，
+每次写入后整体落盘为 {dir}/{collection}.json。
+
+几万个片段以内检索仍在毫秒级，足够开发机和测试使用，不需要 Docker；
+知识库更大或要多进程并发写入时请用 Qdrant。API 与 cmd/ingest 共用同一文件时，
+每次读写前检查文件修改时间，被另一进程更新过就重新加载（与关键词索引相同）。
+*/
+type Embedded struct {
+	mu      sync.RWMutex
+	path    string
+	modTime time.Time
+	dim     int
+	points  map[string]*embeddedPoint
+}
+
+type embeddedPoint struct {
+	Point
+	norm float64 // 向量的模，检索时免去重复计算
+}
+
+// embeddedFile 磁盘格式
+type embeddedFile struct {
+	Dim    int     `json:"dim"`
+	Points []Point `json:"points"`
+}
+
+var _ VectorStore = (*Embedded)(nil)
+
+// OpenEmbedded 打开 dir 下名为 collection 的集合，文件不存在时为空集合
+func OpenEmbedded(dir, collection string) (*Embedded, error) {
+	e := &Embedded{
+		path:   filepath.Join(dir, collection+".json"),
+		points: map[string]*embeddedPoint{},
+	}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// load 从磁盘重建；调用方需持有写锁（OpenEmbedded 除外）
+func (e *Embedded) load() error {
+	info, err := os.Stat(e.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(e.path)
+	if err != nil {
+		return err
+	}
+	var f embeddedFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("解析向量文件 %s 失败: %w", e.path, err)
+	}
+	e.dim = f.Dim
+	e.points = make(map[string]*embeddedPoint, len(f.Points))
+	for _, p := range f.Points {
+		e.points[p.ID] = &embeddedPoint{Point: p, norm: norm(p.Vector)}
+	}
+	e.modTime = info.ModTime()
+	return nil
+}
+
+// refresh 文件被其它进程改写过时重新加载；调用方需持有写锁
+func (e *Embedded) refresh() error {
+	info, err := os.Stat(e.path)
+	if err != nil || info.ModTime().Equal(e.modTime) {
+		return nil
+	}
+	return e.load()
+}
+
+// read 以读锁执行 fn，执行前按需重新加载
+func (e *Embedded) read(fn func() error) error {
+	e.mu.Lock()
+	err := e.refresh()
+	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return fn()
+}
+
+// save 按 ID 排序写临时文件后 rename；调用方需持有写锁
+func (e *Embedded) save() error {
+	if err := os.MkdirAll(filepath.Dir(e.path), 0o755); err != nil {
+		return err
+	}
+	f := embeddedFile{Dim: e.dim, Points: make([]Point, 0, len(e.points))}
+	for _, id := range e.sortedIDs() {
+		f.Points = append(f.Points, e.points[id].Point)
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, e.path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(e.path); err == nil {
+		e.modTime = info.ModTime()
+	}
+	return nil
+}
+
+func (e *Embedded) sortedIDs() []string {
+	ids := make([]string, 0, len(e.points))
+	for id := range e.points {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// EnsureCollection 空集合时记录维度；已有数据且维度不同则报错，需要换模型后重建
+func (e *Embedded) EnsureCollection(dim int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.refresh(); err != nil {
+		return err
+	}
+	if e.dim == dim {
+		return nil
+	}
+	if e.dim != 0 && len(e.points) > 0 {
+		return fmt.Errorf("集合 %s 的向量维度为 %d，与配置的 %d 不一致", e.path, e.dim, dim)
+	}
+	e.dim = dim
+	return e.save()
+}
+
+func (e *Embedded) Dimension(_ context.Context) (int, error) {
+	var dim int
+	err := e.read(func() error {
+		if len(e.points) > 0 {
+			dim = e.dim
+		}
+		return nil
+	})
+	return dim, err
+}
+
+func (e *Embedded) Upsert(_ context.Context, points []Point) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.refresh(); err != nil {
+		return err
+	}
+	for _, p := range points {
+		if e.dim != 0 && len(p.Vector) != e.dim {
+			return fmt.Errorf("点 %s 的向量维度为 %d，集合要求 %d", p.ID, len(p.Vector), e.dim)
+		}
+	}
+	for _, p := range points {
+		e.points[p.ID] = &embeddedPoint{Point: p, norm: norm(p.Vector)}
+	}
+	return e.save()
+}
+
+// Search 逐个计算余弦相似度，语义与 Qdrant 的 /points/query 一致（score_threshold、offset、filter）
+func (e *Embedded) Search(_ context.Context, p SearchParams) ([]Hit, error) {
+	var hits []Hit
+	err := e.read(func() error {
+		qn := norm(p.Vector)
+		type scored struct {
+			pt    *embeddedPoint
+			score float32
+		}
+		var cands []scored
+		for _, pt := range e.points {
+			if !p.Filter.Match(pt.Payload) {
+				continue
+			}
+			s := float32(0)
+			if qn > 0 && pt.norm > 0 && len(pt.Vector) == len(p.Vector) {
+				s = float32(dot(p.Vector, pt.Vector) / (qn * pt.norm))
+			}
+			if p.ScoreThreshold > 0 && s < p.ScoreThreshold {
+				continue
+			}
+			cands = append(cands, scored{pt, s})
+		}
+		sort.Slice(cands, func(i, j int) bool {
+			if cands[i].score != cands[j].score {
+				return cands[i].score > cands[j].score
+			}
+			return cands[i].pt.ID < cands[j].pt.ID
+		})
+		if p.Offset >= len(cands) {
+			return nil
+		}
+		cands = cands[p.Offset:]
+		if p.Limit > 0 && len(cands) > p.Limit {
+			cands = cands[:p.Limit]
+		}
+		for _, c := range cands {
+			if _, ok := c.pt.Payload["text"].(string); !ok {
+				continue
+			}
+			hit := NewHit(c.pt.ID, c.score, c.pt.Payload)
+			if p.WithVector {
+				hit.Vector = c.pt.Vector
+			}
+			hits = append(hits, hit)
+		}
+		return nil
+	})
+	return hits, err
+}
+
+func (e *Embedded) Delete(_ context.Context, filter *Filter) error {
+	if filter.Empty() {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.refresh(); err != nil {
+		return err
+	}
+	n := len(e.points)
+	for id, pt := range e.points {
+		if filter.Match(pt.Payload) {
+			delete(e.points, id)
+		}
+	}
+	if len(e.points) == n {
+		return nil
+	}
+	return e.save()
+}
+
+func (e *Embedded) Count(_ context.Context) (int, error) {
+	var n int
+	err := e.read(func() error {
+		n = len(e.points)
+		return nil
+	})
+	return n, err
+}
+
+// Scroll offset 为本页第一个点的 ID（含），与 Qdrant 的 next_page_offset 语义相同
+func (e *Embedded) Scroll(_ context.Context, p ScrollParams) ([]Point, string, error) {
+	var (
+		out  []Point
+		next string
+	)
+	err := e.read(func() error {
+		limit := p.Limit
+		if limit <= 0 {
+			limit = 10
+		}
+		for _, id := range e.sortedIDs() {
+			if id < p.Offset {
+				continue
+			}
+			pt := e.points[id]
+			if !p.Filter.Match(pt.Payload) {
+				continue
+			}
+			if len(out) == limit {
+				next = id
+				break
+			}
+			cp := pt.Point
+			if !p.WithVector {
+				cp.Vector = nil
+			}
+			out = append(out, cp)
+		}
+		return nil
+	})
+	return out, next, err
+}
+
+func dot(a, b []float32) float64 {
+	var s float64
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
+
+func norm(v []float32) float64 {
+	return math.Sqrt(dot(v, v))
+}
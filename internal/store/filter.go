@@ -0,0 +1,156 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// FilterKeys 允许过滤的 payload 字段
+var FilterKeys = map[string]bool{
+	"course":     true,
+	"experiment": true,
+	"language":   true,
+	"file_type":  true,
+	"source":     true,
+	"tags":       true,
+}
+
+/*
+Filter 检索过滤条件，对应 Qdrant 的 must / should / must_not
+
+	{"must": {"course": "microwave"}, "should": {"file_type": ["pdf", "docx"]}, "must_not": {"tags": "draft"}}
+
+每个字段可以给一个值或一组值，一组值表示命中其一即可；
+must 中的字段全部满足、should 中至少一个字段满足（为空则不要求）、must_not 中的字段都不满足。
+*/
+type Filter struct {
+	Must    Conditions `json:"must,omitempty"`
+	Should  Conditions `json:"should,omitempty"`
+	MustNot Conditions `json:"must_not,omitempty"`
+}
+
+// Conditions payload 字段 → 可接受的取值
+type Conditions map[string]Values
+
+// Values 可以从 JSON 字符串或字符串数组解析
+type Values []string
+
+func (v *Values) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*v = Values{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return fmt.Errorf("过滤条件的取值必须是字符串或字符串数组")
+	}
+	*v = many
+	return nil
+}
+
+// Empty 没有任何条件
+func (f *Filter) Empty() bool {
+	return f == nil || len(f.Must)+len(f.Should)+len(f.MustNot) == 0
+}
+
+// Validate 检查字段名是否允许过滤、取值是否为空
+func (f *Filter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	for _, conds := range []Conditions{f.Must, f.Should, f.MustNot} {
+		for key, vals := range conds {
+			if !FilterKeys[key] {
+				return fmt.Errorf("不支持按 %s 过滤", key)
+			}
+			if len(vals) == 0 {
+				return fmt.Errorf("过滤字段 %s 没有取值", key)
+			}
+		}
+	}
+	return nil
+}
+
+// qdrant 转为 Qdrant 的 filter 对象，nil 表示不过滤
+func (f *Filter) qdrant() map[string]interface{} {
+	if f.Empty() {
+		return nil
+	}
+	out := map[string]interface{}{}
+	for name, conds := range map[string]Conditions{"must": f.Must, "should": f.Should, "must_not": f.MustNot} {
+		if len(conds) > 0 {
+			out[name] = conds.qdrant()
+		}
+	}
+	return out
+}
+
+func (c Conditions) qdrant() []map[string]interface{} {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // 固定顺序，便于排查请求
+	out := make([]map[string]interface{}, 0, len(keys))
+	for _, k := range keys {
+		match := map[string]interface{}{"any": []string(c[k])}
+		if len(c[k]) == 1 {
+			match = map[string]interface{}{"value": c[k][0]}
+		}
+		out = append(out, map[string]interface{}{"key": k, "match": match})
+	}
+	return out
+}
+
+// Match 在内存中按与 Qdrant 相同的语义判断 payload 是否满足条件，供关键词索引等非 Qdrant 召回使用
+func (f *Filter) Match(payload map[string]interface{}) bool {
+	if f.Empty() {
+		return true
+	}
+	for k, vals := range f.Must {
+		if !fieldMatches(payload[k], vals) {
+			return false
+		}
+	}
+	for k, vals := range f.MustNot {
+		if fieldMatches(payload[k], vals) {
+			return false
+		}
+	}
+	if len(f.Should) == 0 {
+		return true
+	}
+	for k, vals := range f.Should {
+		if fieldMatches(payload[k], vals) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldMatches 字段值（或数组字段中的任一元素）等于 vals 中的任一值
+func fieldMatches(field interface{}, vals Values) bool {
+	var have []string
+	switch v := field.(type) {
+	case string:
+		have = []string{v}
+	case []string:
+		have = v
+	case []interface{}:
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				have = append(have, s)
+			}
+		}
+	}
+	for _, h := range have {
+		for _, want := range vals {
+			if h == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		wantErr bool
+	}{
+		{"空", `{}`, false},
+		{"单值与数组", `{"must":{"course":"microwave"},"should":{"file_type":["pdf","docx"]}}`, false},
+		{"must_not", `{"must_not":{"tags":"draft"}}`, false},
+		{"不允许的字段", `{"must":{"author":"x"}}`, true},
+		{"空数组", `{"should":{"course":[]}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f Filter
+			if err := json.Unmarshal([]byte(tt.filter), &f); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var nilFilter *Filter
+	if err := nilFilter.Validate(); err != nil {
+		t.Errorf("nil filter: %v", err)
+	}
+	var f Filter
+	if err := json.Unmarshal([]byte(`{"must":{"course":1}}`), &f); err == nil {
+		t.Error("非字符串取值应解析失败")
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	payload := map[string]interface{}{
+		"course":    "microwave",
+		"file_type": "pdf",
+		"tags":      []interface{}{"lab", "draft"},
+	}
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   bool
+	}{
+		{"nil", nil, true},
+		{"must 命中", &Filter{Must: Conditions{"course": {"microwave"}}}, true},
+		{"must 未命中", &Filter{Must: Conditions{"course": {"optics"}}}, false},
+		{"must 多值命中其一", &Filter{Must: Conditions{"course": {"optics", "microwave"}}}, true},
+		{"must 缺字段", &Filter{Must: Conditions{"language": {"zh"}}}, false},
+		{"数组字段", &Filter{Must: Conditions{"tags": {"lab"}}}, true},
+		{"must_not 排除", &Filter{MustNot: Conditions{"tags": {"draft"}}}, false},
+		{"must_not 未命中", &Filter{MustNot: Conditions{"tags": {"old"}}}, true},
+		{"should 一项满足", &Filter{Should: Conditions{"file_type": {"docx"}, "course": {"microwave"}}}, true},
+		{"should 都不满足", &Filter{Should: Conditions{"file_type": {"docx"}, "course": {"optics"}}}, false},
+		{"组合", &Filter{
+			Must:    Conditions{"course": {"microwave"}},
+			Should:  Conditions{"file_type": {"pdf", "docx"}},
+			MustNot: Conditions{"language": {"en"}},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(payload); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterQdrant(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   map[string]interface{}
+	}{
+		{"nil", nil, nil},
+		{"空", &Filter{}, nil},
+		{"单值用 value、多值用 any，按字段名排序", &Filter{
+			Must:    Conditions{"experiment": {"lab3"}, "course": {"microwave"}},
+			MustNot: Conditions{"tags": {"draft", "old"}},
+		}, map[string]interface{}{
+			"must": []map[string]interface{}{
+				{"key": "course", "match": map[string]interface{}{"value": "microwave"}},
+				{"key": "experiment", "match": map[string]interface{}{"value": "lab3"}},
+			},
+			"must_not": []map[string]interface{}{
+				{"key": "tags", "match": map[string]interface{}{"any": []string{"draft", "old"}}},
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.qdrant(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("qdrant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
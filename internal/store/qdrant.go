@@ -16,12 +16,15 @@ type Point struct {
 	Payload map[string]interface{} `json:"payload"`
 }
 
-// Client 用于通过 Qdrant 的 HTTP API 做向量检索
+// Client 用于通过 Qdrant 的 HTTP API 做向量检索，实现 VectorStore
 type Client struct {
 	client     *resty.Client
 	collection string
+	batchSize  int // 单次 upsert 请求的点数上限
 }
 
+var _ VectorStore = (*Client)(nil)
+
 // NewClient 初始化 Resty 客户端，BaseURL 即 cfg.QdrantURL（例如 "http://localhost:6333"）
 func NewClient(cfg *config.Config) *Client {
 	cli := resty.New().
@@ -30,23 +33,62 @@ func NewClient(cfg *config.Config) *Client {
 	return &Client{
 		client:     cli,
 		collection: cfg.QdrantCol, // e.g. "physics"
+		batchSize:  cfg.UpsertBatchSize,
 	}
 }
 
-// Search 调用 Qdrant 的 /collections/{collection}/points/query 接口，返回 payload["text"]
-func (c *Client) Search(ctx context.Context, vector []float32, topK int) ([]string, error) {
+// Hit 一条检索结果：点 ID、相似度与 payload 中的常用字段
+type Hit struct {
+	ID       string                 `json:"id"`
+	Score    float32                `json:"score"`
+	Text     string                 `json:"text"`
+	Source   string                 `json:"source"`
+	Index    int                    `json:"index"`              // 文件内的切片序号
+	Page     int                    `json:"page,omitempty"`     // 页码，未知时为 0
+	Slide    int                    `json:"slide,omitempty"`    // 幻灯片序号，未知时为 0
+	Section  string                 `json:"section,omitempty"`  // 标题路径，如 "3 实验原理 > 3.2 巨磁阻效应"
+	Headings []string               `json:"headings,omitempty"` // 标题路径的各级标题
+	Vector   []float32              `json:"-"`                  // 仅在 SearchParams.WithVector 时返回
+	Payload  map[string]interface{} `json:"-"`                  // 原始 payload，供上层读取其它字段
+}
+
+// SearchParams 向量检索参数
+type SearchParams struct {
+	Vector         []float32
+	Limit          int
+	Offset         int     // 跳过前 Offset 个结果，用于分页
+	ScoreThreshold float32 // 只返回相似度不低于该值的结果，0 表示不限
+	WithVector     bool    // 同时返回点的向量（MMR 等重排需要）
+	Filter         *Filter // 可选的 payload 过滤条件
+}
+
+// Search 调用 Qdrant 的 /collections/{collection}/points/query 接口，按相似度返回结构化结果
+func (c *Client) Search(ctx context.Context, p SearchParams) ([]Hit, error) {
 	// 调用 /collections/{col}/points/query
 	url := fmt.Sprintf("/collections/%s/points/query", c.collection)
 	body := map[string]interface{}{
-		"query":        vector,
-		"limit":        topK,
+		"query":        p.Vector,
+		"limit":        p.Limit,
 		"with_payload": true,
+		"with_vector":  p.WithVector,
+	}
+	if filter := p.Filter.qdrant(); filter != nil {
+		body["filter"] = filter
+	}
+	if p.Offset > 0 {
+		body["offset"] = p.Offset
+	}
+	if p.ScoreThreshold > 0 {
+		body["score_threshold"] = p.ScoreThreshold
 	}
 
 	var resp struct {
 		Result struct {
 			Points []struct {
+				ID      interface{}            `json:"id"` // uuid 字符串或整数
+				Score   float32                `json:"score"`
 				Payload map[string]interface{} `json:"payload"`
+				Vector  []float32              `json:"vector"`
 			} `json:"points"`
 		} `json:"result"`
 	}
@@ -63,23 +105,83 @@ func (c *Client) Search(ctx context.Context, vector []float32, topK int) ([]stri
 		return nil, fmt.Errorf("qdrant search error: %s", r.Status())
 	}
 
-	var texts []string
+	hits := make([]Hit, 0, len(resp.Result.Points))
 	for _, pt := range resp.Result.Points {
-		if txt, ok := pt.Payload["text"].(string); ok {
-			texts = append(texts, txt)
+		if _, ok := pt.Payload["text"].(string); !ok {
+			continue
 		}
+		hit := NewHit(fmt.Sprint(pt.ID), pt.Score, pt.Payload)
+		hit.Vector = pt.Vector
+		hits = append(hits, hit)
 	}
-	return texts, nil
+	return hits, nil
+}
+
+// NewHit 从 payload 构造检索结果，供向量检索以外的召回方式（如关键词索引）复用
+func NewHit(id string, score float32, payload map[string]interface{}) Hit {
+	return Hit{
+		ID:       id,
+		Score:    score,
+		Text:     payloadString(payload, "text"),
+		Source:   payloadString(payload, "source"),
+		Index:    payloadInt(payload, "index"),
+		Page:     payloadInt(payload, "page"),
+		Slide:    payloadInt(payload, "slide"),
+		Section:  payloadString(payload, "section"),
+		Headings: payloadStrings(payload, "headings"),
+		Payload:  payload,
+	}
+}
+
+// payloadString 读取字符串字段，缺失时返回空串
+func payloadString(p map[string]interface{}, key string) string {
+	s, _ := p[key].(string)
+	return s
+}
+
+// payloadStrings 读取字符串数组字段
+func payloadStrings(p map[string]interface{}, key string) []string {
+	if ss, ok := p[key].([]string); ok {
+		return ss
+	}
+	arr, _ := p[key].([]interface{})
+	out := make([]string, 0, len(arr))
+	for _, v := range arr {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// payloadInt 读取数值字段；JSON 解码后数字统一是 float64
+func payloadInt(p map[string]interface{}, key string) int {
+	switch v := p[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
 }
 
 // EnsureCollection internal/store/qdrant.go  片段
 func (c *Client) EnsureCollection(dim int) error {
 	url := fmt.Sprintf("/collections/%s", c.collection)
 
-	// 先尝试 GET
-	r, err := c.client.R().Get(url)
-	if err == nil && r.StatusCode() == http.StatusOK {
-		return nil // 已存在
+	// 先查询已有集合（或别名指向的版本集合）的维度
+	actual, err := c.Dimension(context.Background())
+	if err != nil {
+		return err
+	}
+	if actual != 0 {
+		if actual != dim {
+			return fmt.Errorf("集合 %s 的向量维度为 %d，与配置的 %d 不一致", c.collection, actual, dim)
+		}
+		return c.ensurePayloadIndexes() // 已存在；老集合可能还没有过滤字段的索引
 	}
 
 	// 不存在就创建
@@ -89,7 +191,7 @@ func (c *Client) EnsureCollection(dim int) error {
 			"distance": "Cosine",
 		},
 	}
-	r, err = c.client.R().
+	r, err := c.client.R().
 		SetBody(body).
 		Put(url)
 	if err != nil {
@@ -98,17 +200,90 @@ func (c *Client) EnsureCollection(dim int) error {
 	if r.IsError() {
 		return fmt.Errorf("create collection: %s", r.Status())
 	}
+	return c.ensurePayloadIndexes()
+}
+
+// Dimension 读取 GET /collections/{collection} 中的向量维度，collection 为别名时读取其指向的集合；集合不存在时返回 0
+func (c *Client) Dimension(ctx context.Context) (int, error) {
+	name, err := c.target(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		Result struct {
+			Config struct {
+				Params struct {
+					Vectors struct {
+						Size int `json:"size"`
+					} `json:"vectors"`
+				} `json:"params"`
+			} `json:"config"`
+		} `json:"result"`
+	}
+	r, err := c.client.R().
+		SetContext(ctx).
+		SetResult(&resp).
+		Get(fmt.Sprintf("/collections/%s", name))
+	if err != nil {
+		return 0, err
+	}
+	if r.StatusCode() == http.StatusNotFound {
+		return 0, nil
+	}
+	if r.IsError() {
+		return 0, fmt.Errorf("qdrant get collection error: %s", r.Status())
+	}
+	return resp.Result.Config.Params.Vectors.Size, nil
+}
+
+// ensurePayloadIndexes 为可过滤的 payload 字段建 keyword 索引，已存在的索引 Qdrant 会直接返回成功
+func (c *Client) ensurePayloadIndexes() error {
+	name, err := c.target(context.Background())
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("/collections/%s/index", name)
+	for key := range FilterKeys {
+		r, err := c.client.R().
+			SetBody(map[string]interface{}{"field_name": key, "field_schema": "keyword"}).
+			Put(url)
+		if err != nil {
+			return err
+		}
+		if r.IsError() {
+			return fmt.Errorf("create payload index %s: %s", key, r.Status())
+		}
+	}
 	return nil
 }
 
-// Upsert 批量写入或更新向量点到 Qdrant（注意：用 PUT）
+// Upsert 批量写入或更新向量点到 Qdrant（注意：用 PUT）。
+// 按 batchSize 分批提交，每批带 wait=true，返回时数据已可检索。
 func (c *Client) Upsert(ctx context.Context, points []Point) error {
+	size := c.batchSize
+	if size <= 0 {
+		size = len(points)
+	}
+	for start := 0; start < len(points); start += size {
+		end := start + size
+		if end > len(points) {
+			end = len(points)
+		}
+		if err := c.upsertBatch(ctx, points[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) upsertBatch(ctx context.Context, points []Point) error {
 	// Endpoint 必须是 PUT /collections/{col}/points
 	url := fmt.Sprintf("/collections/%s/points", c.collection)
 	body := map[string]interface{}{"points": points}
 
 	resp, err := c.client.R().
 		SetContext(ctx).
+		SetQueryParam("wait", "true").
 		SetBody(body).
 		Put(url) // ← 这里改成 Put
 	if err != nil {
@@ -120,3 +295,96 @@ func (c *Client) Upsert(ctx context.Context, points []Point) error {
 	}
 	return nil
 }
+
+// Delete 删除满足 filter 的所有点，等待删除落盘后返回；filter 为空时直接返回
+func (c *Client) Delete(ctx context.Context, filter *Filter) error {
+	if filter.Empty() {
+		return nil
+	}
+	url := fmt.Sprintf("/collections/%s/points/delete", c.collection)
+	body := map[string]interface{}{"filter": filter.qdrant()}
+
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetQueryParam("wait", "true").
+		SetBody(body).
+		Post(url)
+	if err != nil {
+		return fmt.Errorf("qdrant delete request failed: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("qdrant delete error: %s — %s", resp.Status(), resp.String())
+	}
+	return nil
+}
+
+// Count 精确统计 collection 中的点数
+func (c *Client) Count(ctx context.Context) (int, error) {
+	url := fmt.Sprintf("/collections/%s/points/count", c.collection)
+
+	var resp struct {
+		Result struct {
+			Count int `json:"count"`
+		} `json:"result"`
+	}
+	r, err := c.client.R().
+		SetContext(ctx).
+		SetBody(map[string]interface{}{"exact": true}).
+		SetResult(&resp).
+		Post(url)
+	if err != nil {
+		return 0, err
+	}
+	if r.IsError() {
+		return 0, fmt.Errorf("qdrant count error: %s", r.Status())
+	}
+	return resp.Result.Count, nil
+}
+
+// Scroll 调用 /points/scroll 按 ID 顺序分页遍历点
+func (c *Client) Scroll(ctx context.Context, p ScrollParams) ([]Point, string, error) {
+	url := fmt.Sprintf("/collections/%s/points/scroll", c.collection)
+	body := map[string]interface{}{
+		"limit":        p.Limit,
+		"with_payload": true,
+		"with_vector":  p.WithVector,
+	}
+	if p.Offset != "" {
+		body["offset"] = p.Offset
+	}
+	if filter := p.Filter.qdrant(); filter != nil {
+		body["filter"] = filter
+	}
+
+	var resp struct {
+		Result struct {
+			Points []struct {
+				ID      interface{}            `json:"id"`
+				Payload map[string]interface{} `json:"payload"`
+				Vector  []float32              `json:"vector"`
+			} `json:"points"`
+			NextPageOffset interface{} `json:"next_page_offset"` // uuid 字符串、整数或 null
+		} `json:"result"`
+	}
+	r, err := c.client.R().
+		SetContext(ctx).
+		SetBody(body).
+		SetResult(&resp).
+		Post(url)
+	if err != nil {
+		return nil, "", err
+	}
+	if r.IsError() {
+		return nil, "", fmt.Errorf("qdrant scroll error: %s", r.Status())
+	}
+
+	points := make([]Point, len(resp.Result.Points))
+	for i, pt := range resp.Result.Points {
+		points[i] = Point{ID: fmt.Sprint(pt.ID), Vector: pt.Vector, Payload: pt.Payload}
+	}
+	var next string
+	if resp.Result.NextPageOffset != nil {
+		next = fmt.Sprint(resp.Result.NextPageOffset)
+	}
+	return points, next, nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iammm0/physics-llm/internal/config"
+)
+
+// 可选的向量库后端（VECTOR_STORE）
+const (
+	BackendQdrant   = "qdrant"   // Qdrant HTTP API
+	BackendEmbedded = "embedded" // 进程内暴力检索，持久化为本地 JSON 文件，开发调试用
+)
+
+// VectorStore 向量库；Qdrant 客户端与内嵌实现都满足该接口，导入与检索只依赖它
+type VectorStore interface {
+	// EnsureCollection 确保集合存在且向量维度为 dim；集合已存在但维度不同时返回 error
+	EnsureCollection(dim int) error
+	// Dimension 已有集合的向量维度；集合不存在（内嵌实现为没有任何点）时返回 0
+	Dimension(ctx context.Context) (int, error)
+	// Upsert 按 ID 写入或覆盖点，返回时数据已可检索
+	Upsert(ctx context.Context, points []Point) error
+	// Search 按余弦相似度检索
+	Search(ctx context.Context, p SearchParams) ([]Hit, error)
+	// Delete 删除满足 filter 的全部点；filter 为空时不做任何事，避免误删整个集合
+	Delete(ctx context.Context, filter *Filter) error
+	// Count 精确统计点数
+	Count(ctx context.Context) (int, error)
+	// Scroll 按 ID 顺序分页遍历点，返回下一页的起始 offset，遍历完时为空串
+	Scroll(ctx context.Context, p ScrollParams) ([]Point, string, error)
+}
+
+// ScrollParams 遍历参数
+type ScrollParams struct {
+	Limit      int
+	Offset     string  // 上一页返回的 offset，首页留空
+	WithVector bool    // 同时返回向量
+	Filter     *Filter // 可选的 payload 过滤条件
+}
+
+// SourceFilter 匹配某个 source 的全部片段
+func SourceFilter(source string) *Filter {
+	return &Filter{Must: Conditions{"source": {source}}}
+}
+
+// New 按 VECTOR_STORE 创建向量库
+func New(cfg *config.Config) (VectorStore, error) {
+	switch cfg.VectorStore {
+	case BackendQdrant, "":
+		return NewClient(cfg), nil
+	case BackendEmbedded:
+		return OpenEmbedded(cfg.EmbeddedStoreDir, cfg.QdrantCol)
+	}
+	return nil, fmt.Errorf("未知的 VECTOR_STORE: %s", cfg.VectorStore)
+}
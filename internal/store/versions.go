@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iammm0/physics-llm/internal/config"
+)
+
+/*
+Versions 集合的版本管理，用于蓝绿重建
+
+每次重建写入新集合 {alias}_vN，完成后把别名（即 QDRANT_COLLECTION）原子地切到新集合；
+检索与增量导入始终通过别名读写，重建期间服务不受影响，旧版本保留以便回滚。
+*/
+type Versions interface {
+	// List 返回全部版本集合名，按版本号升序
+	List(ctx context.Context) ([]string, error)
+	// Active 别名当前指向的集合，还没有别名时返回空串
+	Active(ctx context.Context) (string, error)
+	// Legacy 是否存在与别名同名的普通集合（启用版本管理之前创建的）
+	Legacy(ctx context.Context) (bool, error)
+	// Switch 把别名指向 collection；存在同名普通集合时，dropLegacy 为 true 才删除它，否则返回 ErrLegacyCollection
+	Switch(ctx context.Context, collection string, dropLegacy bool) error
+	// Drop 删除集合及其全部点
+	Drop(ctx context.Context, collection string) error
+}
+
+// ErrVersionsUnsupported 内嵌向量库没有别名，不支持蓝绿重建
+var ErrVersionsUnsupported = errors.New("内嵌向量库不支持版本管理，蓝绿重建需要 VECTOR_STORE=qdrant")
+
+// ErrLegacyCollection 与别名同名的普通集合挡住了别名，删除后才能切换，且无法再回滚到它
+var ErrLegacyCollection = errors.New("存在与别名同名的普通集合")
+
+var _ Versions = (*Client)(nil)
+
+// NewVersions 按 VECTOR_STORE 创建版本管理，别名为 QDRANT_COLLECTION
+func NewVersions(cfg *config.Config) (Versions, error) {
+	switch cfg.VectorStore {
+	case BackendQdrant, "":
+		return NewClient(cfg), nil
+	case BackendEmbedded:
+		return nil, ErrVersionsUnsupported
+	}
+	return nil, fmt.Errorf("未知的 VECTOR_STORE: %s", cfg.VectorStore)
+}
+
+// VersionName 第 n 个版本的集合名，如 physics_v3
+func VersionName(alias string, n int) string {
+	return fmt.Sprintf("%s_v%d", alias, n)
+}
+
+// VersionNumber 解析版本集合名中的版本号；name 不是 alias 的版本集合时返回 false
+func VersionNumber(alias, name string) (int, bool) {
+	rest, ok := strings.CutPrefix(name, alias+"_v")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	if err != nil || n <= 0 || strconv.Itoa(n) != rest {
+		return 0, false
+	}
+	return n, true
+}
+
+// List 调用 GET /collections，筛出 {collection}_vN 形式的集合
+func (c *Client) List(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Result struct {
+			Collections []struct {
+				Name string `json:"name"`
+			} `json:"collections"`
+		} `json:"result"`
+	}
+	r, err := c.client.R().
+		SetContext(ctx).
+		SetResult(&resp).
+		Get("/collections")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, fmt.Errorf("qdrant list collections error: %s", r.Status())
+	}
+
+	var names []string
+	for _, col := range resp.Result.Collections {
+		if _, ok := VersionNumber(c.collection, col.Name); ok {
+			names = append(names, col.Name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := VersionNumber(c.collection, names[i])
+		b, _ := VersionNumber(c.collection, names[j])
+		return a < b
+	})
+	return names, nil
+}
+
+// Active 在 GET /aliases 中查找别名指向的集合
+func (c *Client) Active(ctx context.Context) (string, error) {
+	var resp struct {
+		Result struct {
+			Aliases []struct {
+				AliasName      string `json:"alias_name"`
+				CollectionName string `json:"collection_name"`
+			} `json:"aliases"`
+		} `json:"result"`
+	}
+	r, err := c.client.R().
+		SetContext(ctx).
+		SetResult(&resp).
+		Get("/aliases")
+	if err != nil {
+		return "", err
+	}
+	if r.IsError() {
+		return "", fmt.Errorf("qdrant list aliases error: %s", r.Status())
+	}
+	for _, a := range resp.Result.Aliases {
+		if a.AliasName == c.collection {
+			return a.CollectionName, nil
+		}
+	}
+	return "", nil
+}
+
+// Legacy 别名不存在而 GET /collections/{collection} 成功时，说明是同名的普通集合
+func (c *Client) Legacy(ctx context.Context) (bool, error) {
+	active, err := c.Active(ctx)
+	if err != nil || active != "" {
+		return false, err
+	}
+	r, err := c.client.R().SetContext(ctx).Get(fmt.Sprintf("/collections/%s", c.collection))
+	if err != nil {
+		return false, err
+	}
+	switch r.StatusCode() {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("qdrant get collection error: %s", r.Status())
+}
+
+/*
+Switch 在一个 POST /collections/aliases 请求里删除旧别名并创建新别名，Qdrant 保证两步原子生效，
+检索不会读到不存在的集合。
+
+同名的普通集合（启用版本管理之前创建的）会挡住别名，默认拒绝切换；dropLegacy 为 true 时先删除它，
+这一次切换不是原子的，也无法回滚到它。
+*/
+func (c *Client) Switch(ctx context.Context, collection string, dropLegacy bool) error {
+	active, err := c.Active(ctx)
+	if err != nil {
+		return err
+	}
+	var actions []map[string]interface{}
+	if active != "" {
+		actions = append(actions, map[string]interface{}{
+			"delete_alias": map[string]interface{}{"alias_name": c.collection},
+		})
+	} else {
+		legacy, err := c.Legacy(ctx)
+		if err != nil {
+			return err
+		}
+		if legacy {
+			if !dropLegacy {
+				return fmt.Errorf("%w: %s", ErrLegacyCollection, c.collection)
+			}
+			if err := c.Drop(ctx, c.collection); err != nil {
+				return err
+			}
+		}
+	}
+	actions = append(actions, map[string]interface{}{
+		"create_alias": map[string]interface{}{"collection_name": collection, "alias_name": c.collection},
+	})
+
+	r, err := c.client.R().
+		SetContext(ctx).
+		SetBody(map[string]interface{}{"actions": actions}).
+		Post("/collections/aliases")
+	if err != nil {
+		return err
+	}
+	if r.IsError() {
+		return fmt.Errorf("qdrant switch alias error: %s — %s", r.Status(), r.String())
+	}
+	return nil
+}
+
+// Drop 调用 DELETE /collections/{collection}
+func (c *Client) Drop(ctx context.Context, collection string) error {
+	r, err := c.client.R().
+		SetContext(ctx).
+		Delete(fmt.Sprintf("/collections/%s", collection))
+	if err != nil {
+		return err
+	}
+	if r.IsError() {
+		return fmt.Errorf("qdrant drop collection %s error: %s", collection, r.Status())
+	}
+	return nil
+}
+
+// target 别名解析后的实际集合名；集合信息、索引等管理接口不一定接受别名，需要先解析
+func (c *Client) target(ctx context.Context) (string, error) {
+	active, err := c.Active(ctx)
+	if err != nil || active == "" {
+		return c.collection, err
+	}
+	return active, nil
+}